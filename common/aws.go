@@ -0,0 +1,125 @@
+package common
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// AWSConfig configures the AWS service clients (see: AWSClients).  Only Region is required; The endpoint, and
+// credential attributes point clients at S3-compatible (or otherwise AWS-compatible) stand-ins, such as MinIO,
+// LocalStack, or DynamoDB Local, for example during integration testing.
+type AWSConfig struct {
+	Region string
+
+	// Endpoint (URL) of every service; Empty to use those of AWS.  S3Endpoint, DynamoDBEndpoint, SNSEndpoint, and
+	// SQSEndpoint take precedence (for the respective service) when set.
+	Endpoint         string
+	S3Endpoint       string
+	DynamoDBEndpoint string
+	SNSEndpoint      string
+	SQSEndpoint      string
+
+	// Address S3 buckets by path (http://host/bucket/key), rather than by host name (http://bucket.host/key), as
+	// most S3-compatible servers require.
+	S3ForcePathStyle bool
+
+	// Static credentials; When AccessKeyID is empty, credentials are drawn from the default chain (shared
+	// credentials file, and role).
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// AWSConfigFromEnv returns the AWSConfig for region, overridden by the environment: AWS_ENDPOINT_URL (and
+// AWS_ENDPOINT_URL_S3, AWS_ENDPOINT_URL_DYNAMODB, AWS_ENDPOINT_URL_SNS, and AWS_ENDPOINT_URL_SQS),
+// AWS_S3_FORCE_PATH_STYLE, and the static credentials AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and
+// AWS_SESSION_TOKEN.
+func AWSConfigFromEnv(region string) AWSConfig {
+	var config = AWSConfig{
+		Region:           region,
+		Endpoint:         os.Getenv("AWS_ENDPOINT_URL"),
+		S3Endpoint:       os.Getenv("AWS_ENDPOINT_URL_S3"),
+		DynamoDBEndpoint: os.Getenv("AWS_ENDPOINT_URL_DYNAMODB"),
+		SNSEndpoint:      os.Getenv("AWS_ENDPOINT_URL_SNS"),
+		SQSEndpoint:      os.Getenv("AWS_ENDPOINT_URL_SQS"),
+		AccessKeyID:      os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey:  os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:     os.Getenv("AWS_SESSION_TOKEN"),
+	}
+
+	// Malformed values are ignored
+	if v, err := strconv.ParseBool(os.Getenv("AWS_S3_FORCE_PATH_STYLE")); err == nil {
+		config.S3ForcePathStyle = v
+	}
+
+	return config
+}
+
+// AWSClients creates AWS service clients sharing a session
+type AWSClients struct {
+	Session *session.Session
+	config  AWSConfig
+}
+
+// NewAWSClients returns an AWSClients for config
+func NewAWSClients(config AWSConfig) (*AWSClients, error) {
+	var sess *session.Session
+	var err error
+
+	awsConfig := &aws.Config{Region: aws.String(config.Region)}
+
+	if config.AccessKeyID != "" {
+		awsConfig.Credentials = credentials.NewStaticCredentials(config.AccessKeyID, config.SecretAccessKey, config.SessionToken)
+	}
+
+	if sess, err = session.NewSession(awsConfig); err != nil {
+		return nil, err
+	}
+
+	return &AWSClients{Session: sess, config: config}, nil
+}
+
+// S3 returns an S3 client
+func (c *AWSClients) S3() *s3.S3 {
+	config := c.endpoint(c.config.S3Endpoint)
+	config.S3ForcePathStyle = aws.Bool(c.config.S3ForcePathStyle)
+	return s3.New(c.Session, config)
+}
+
+// DynamoDB returns a DynamoDB client
+func (c *AWSClients) DynamoDB() *dynamodb.DynamoDB {
+	return dynamodb.New(c.Session, c.endpoint(c.config.DynamoDBEndpoint))
+}
+
+// SNS returns an SNS client
+func (c *AWSClients) SNS() *sns.SNS {
+	return sns.New(c.Session, c.endpoint(c.config.SNSEndpoint))
+}
+
+// SQS returns an SQS client
+func (c *AWSClients) SQS() *sqs.SQS {
+	return sqs.New(c.Session, c.endpoint(c.config.SQSEndpoint))
+}
+
+// Returns the configuration of a service client whose endpoint is endpoint (or failing that, Endpoint)
+func (c *AWSClients) endpoint(endpoint string) *aws.Config {
+	var config = &aws.Config{}
+
+	if endpoint == "" {
+		endpoint = c.config.Endpoint
+	}
+
+	if endpoint != "" {
+		config.Endpoint = aws.String(endpoint)
+	}
+
+	return config
+}
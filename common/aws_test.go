@@ -0,0 +1,73 @@
+package common
+
+import (
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAWSClients(t *testing.T) {
+	t.Run("Endpoints", func(t *testing.T) {
+		clients, err := NewAWSClients(AWSConfig{
+			Region:           "us-east-2",
+			Endpoint:         "http://localhost:4566",
+			S3Endpoint:       "http://localhost:9000",
+			S3ForcePathStyle: true,
+			AccessKeyID:      "minioadmin",
+			SecretAccessKey:  "minioadmin",
+		})
+		require.Nil(t, err)
+
+		s3 := clients.S3()
+		assert.Equal(t, "http://localhost:9000", s3.Endpoint)
+		assert.True(t, aws.BoolValue(s3.Config.S3ForcePathStyle))
+		assert.Equal(t, "http://localhost:4566", clients.DynamoDB().Endpoint)
+		assert.Equal(t, "http://localhost:4566", clients.SNS().Endpoint)
+		assert.Equal(t, "http://localhost:4566", clients.SQS().Endpoint)
+
+		creds, err := s3.Config.Credentials.Get()
+		require.Nil(t, err)
+		assert.Equal(t, "minioadmin", creds.AccessKeyID)
+	})
+
+	t.Run("Defaults", func(t *testing.T) {
+		clients, err := NewAWSClients(AWSConfig{Region: "us-east-2"})
+		require.Nil(t, err)
+		assert.Equal(t, "https://s3.us-east-2.amazonaws.com", clients.S3().Endpoint)
+		assert.Equal(t, "https://dynamodb.us-east-2.amazonaws.com", clients.DynamoDB().Endpoint)
+	})
+
+	t.Run("Environment", func(t *testing.T) {
+		env := map[string]string{
+			"AWS_ENDPOINT_URL":        "http://localhost:4566",
+			"AWS_S3_FORCE_PATH_STYLE": "true",
+			"AWS_ACCESS_KEY_ID":       "test",
+			"AWS_SECRET_ACCESS_KEY":   "secret",
+			"AWS_SESSION_TOKEN":       "",
+		}
+		for k, v := range env {
+			defer os.Setenv(k, os.Getenv(k))
+			os.Setenv(k, v)
+		}
+
+		config := AWSConfigFromEnv("us-east-2")
+		assert.Equal(t, AWSConfig{
+			Region:           "us-east-2",
+			Endpoint:         "http://localhost:4566",
+			S3ForcePathStyle: true,
+			AccessKeyID:      "test",
+			SecretAccessKey:  "secret",
+		}, config)
+
+		clients, err := NewAWSClients(config)
+		require.Nil(t, err)
+
+		creds, err := clients.S3().Config.Credentials.Get()
+		require.Nil(t, err)
+		assert.Equal(t, "test", creds.AccessKeyID)
+		assert.Equal(t, "secret", creds.SecretAccessKey)
+	})
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/sns"
 )
 
@@ -37,13 +36,12 @@ func (p *ChangeEventPublisher) arn() string {
 	return fmt.Sprintf("arn:aws:sns:%s:%s:%s", p.region, p.account, p.topic)
 }
 
-// NewChangeEventPublisher returns an initialized ChangeEventPublisher
+// NewChangeEventPublisher returns an initialized ChangeEventPublisher (see: AWSConfigFromEnv)
 func NewChangeEventPublisher(account string, region string, topic string) *ChangeEventPublisher {
-	config := &aws.Config{Region: aws.String(region)}
-	sess, err := session.NewSession(config)
+	clients, err := NewAWSClients(AWSConfigFromEnv(region))
 	if err != nil {
 		panic(err)
 	}
 
-	return &ChangeEventPublisher{client: sns.New(sess), account: account, region: region, topic: topic}
+	return &ChangeEventPublisher{client: clients.SNS(), account: account, region: region, topic: topic}
 }
@@ -68,6 +68,9 @@ type Node struct {
 
 	// The raw HTML context of the corresponding node.
 	Unsafe string `json:"unsafe"`
+
+	// Hash of the node's content (name and HTML); Assigned by storage, and used to detect unchanged nodes.
+	ContentHash string `json:"contentHash,omitempty"`
 }
 
 type metadata struct {
@@ -11,3 +11,8 @@ type ChangeEvent struct {
 type NodeStoredEvent struct {
 	ID string `json:"id"`
 }
+
+// PageStoredEvent is a JSON object that corresponds to a Page being added to (or updated in) the Content Store.
+type PageStoredEvent struct {
+	ID string `json:"id"`
+}
@@ -23,6 +23,19 @@ PHX_SNS_RAW_CONTENT_WD_LINKED     = $(PHX_PREFIX)-sns-raw-content-schemaorg
 # Structured Content Store
 PHX_SNS_NODE_PUBLISHED            = $(PHX_PREFIX)-sns-node-published
 
+# Topic that receives events when Page objects are stored (or updated)
+# in the Structured Content Store
+PHX_SNS_PAGE_PUBLISHED            = $(PHX_PREFIX)-sns-page-published
+
+
+######
+# SQS resources
+######
+
+# Queue (subscribed to PHX_SNS_NODE_PUBLISHED and PHX_SNS_PAGE_PUBLISHED)
+# from which the service receives the events that invalidate its cache
+PHX_SQS_CACHE_INVALIDATION        = $(PHX_PREFIX)-sqs-cache-invalidation
+
 
 ######
 # S3 resources
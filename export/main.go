@@ -0,0 +1,121 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/wikimedia/phoenix/common"
+	"github.com/wikimedia/phoenix/storage"
+)
+
+var (
+	// Command-line flags
+	authorityFlag     = flag.String("authority", "", "only export the pages of this wiki")
+	formatFlag        = flag.String("format", "ndjson", "dump format: ndjson, or tar")
+	gzipFlag          = flag.Bool("gzip", false, "compress the dump (gzip)")
+	modifiedSinceFlag = flag.String("modified-since", "", "only export pages modified since (RFC3339 timestamp, or YYYY-MM-DD)")
+	outputFlag        = flag.String("output", "-", "file to write the dump to")
+	storageFlag       = flag.String("storage-dir", "", "use local storage in directory (instead of S3)")
+
+	// These are assigned during compilation using `-ldflags` (see: Makefile)
+	awsRegion                 string
+	s3StructuredContentBucket string
+)
+
+func init() {
+	if awsRegion == "" {
+		panic("awsRegion is UNSET; not passed in ldflags during compilation!")
+	}
+	if s3StructuredContentBucket == "" {
+		panic("s3StructuredContentBucket is UNSET; not passed in ldflags during compilation!")
+	}
+}
+
+// Parses the -modified-since flag
+func parseModifiedSince() (time.Time, error) {
+	if *modifiedSinceFlag == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse("2006-01-02", *modifiedSinceFlag); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.RFC3339, *modifiedSinceFlag)
+}
+
+func main() {
+	var content *storage.Repository
+	var err error
+	var modifiedSince time.Time
+	var output io.WriteCloser = os.Stdout
+	var stats *storage.DumpStats
+	var writer storage.DumpWriter
+
+	flag.Parse()
+
+	if modifiedSince, err = parseModifiedSince(); err != nil {
+		panic(fmt.Errorf("Invalid -modified-since: %w", err))
+	}
+
+	// Content repository
+	if *storageFlag != "" {
+		if content, err = storage.NewLocalRepository(*storageFlag, s3StructuredContentBucket); err != nil {
+			panic(fmt.Errorf("Unable to open local storage: %w", err))
+		}
+	} else {
+		var awsClients *common.AWSClients
+		if awsClients, err = common.NewAWSClients(common.AWSConfigFromEnv(awsRegion)); err != nil {
+			panic(fmt.Errorf("Unable to create AWS clients: %w", err))
+		}
+		content = &storage.Repository{Store: awsClients.S3(), Bucket: s3StructuredContentBucket}
+	}
+
+	if *outputFlag != "-" {
+		if output, err = os.Create(*outputFlag); err != nil {
+			panic(fmt.Errorf("Unable to create output file: %w", err))
+		}
+	}
+
+	var out io.Writer = output
+	var compressor *gzip.Writer
+
+	if *gzipFlag {
+		compressor = gzip.NewWriter(output)
+		out = compressor
+	}
+
+	switch *formatFlag {
+	case "ndjson":
+		writer = storage.NewJSONDumpWriter(out)
+	case "tar":
+		writer = storage.NewTarDumpWriter(out)
+	default:
+		panic(fmt.Errorf("Unknown dump format: %s", *formatFlag))
+	}
+
+	if stats, err = content.Export(writer, storage.ExportOptions{Authority: *authorityFlag, ModifiedSince: modifiedSince}); err != nil {
+		fmt.Fprintf(os.Stderr, "Export failed: %s\n", err)
+		os.Exit(1)
+	}
+
+	// Close in order, innermost first
+	if err = writer.Close(); err == nil && compressor != nil {
+		err = compressor.Close()
+	}
+	if err == nil {
+		err = output.Close()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Export failed: %s\n", err)
+		os.Exit(1)
+	}
+
+	b, _ := json.Marshal(stats)
+	fmt.Fprintf(os.Stderr, "Export complete: %s\n", b)
+}
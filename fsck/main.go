@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/wikimedia/phoenix/common"
+	"github.com/wikimedia/phoenix/storage"
+)
+
+var (
+	// Command-line flags
+	authorityFlag = flag.String("authority", "", "only check the objects of this wiki")
+	repairFlag    = flag.Bool("repair", false, "repair the problems found")
+	storageFlag   = flag.String("storage-dir", "", "use local storage in directory (instead of S3 and DynamoDB)")
+
+	// These are assigned during compilation using `-ldflags` (see: Makefile)
+	awsRegion                 string
+	dynamoDBNodeNames         string
+	dynamoDBPageTitles        string
+	s3StructuredContentBucket string
+)
+
+func init() {
+	if awsRegion == "" {
+		panic("awsRegion is UNSET; not passed in ldflags during compilation!")
+	}
+	if dynamoDBNodeNames == "" {
+		panic("dynamoDBNodeNames is UNSET; not passed in ldflags during compilation!")
+	}
+	if dynamoDBPageTitles == "" {
+		panic("dynamoDBPageTitles is UNSET; not passed in ldflags during compilation!")
+	}
+	if s3StructuredContentBucket == "" {
+		panic("s3StructuredContentBucket is UNSET; not passed in ldflags during compilation!")
+	}
+}
+
+func main() {
+	var b []byte
+	var content *storage.Repository
+	var err error
+	var report *storage.CheckReport
+
+	flag.Parse()
+
+	// Content repository
+	if *storageFlag != "" {
+		if content, err = storage.NewLocalRepository(*storageFlag, s3StructuredContentBucket); err != nil {
+			panic(fmt.Errorf("Unable to open local storage: %w", err))
+		}
+	} else {
+		var awsClients *common.AWSClients
+		if awsClients, err = common.NewAWSClients(common.AWSConfigFromEnv(awsRegion)); err != nil {
+			panic(fmt.Errorf("Unable to create AWS clients: %w", err))
+		}
+		content = &storage.Repository{
+			Store: awsClients.S3(),
+			Index: &storage.DynamoDBIndex{
+				Client:      awsClients.DynamoDB(),
+				TitlesTable: dynamoDBPageTitles,
+				NamesTable:  dynamoDBNodeNames,
+			},
+			Bucket: s3StructuredContentBucket,
+		}
+	}
+
+	if report, err = content.Check(storage.CheckOptions{Authority: *authorityFlag, Repair: *repairFlag}); err != nil {
+		fmt.Fprintf(os.Stderr, "Check failed: %s\n", err)
+		os.Exit(2)
+	}
+
+	if b, err = json.MarshalIndent(report, "", "  "); err != nil {
+		panic(fmt.Errorf("Unable to serialize report: %w", err))
+	}
+
+	fmt.Println(string(b))
+
+	// Exit non-zero if anything remains to be fixed
+	for _, problem := range report.Problems {
+		if !problem.Repaired {
+			os.Exit(1)
+		}
+	}
+}
@@ -9,9 +9,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/elastic/go-elasticsearch/v7"
 	"github.com/wikimedia/phoenix/common"
 	"github.com/wikimedia/phoenix/rosette"
@@ -26,6 +23,7 @@ var (
 	limitFlag    = flag.Int("limit", -1, "number of items to process")
 	resumeFlag   = flag.String("resume", "", "node ID to resume from")
 	debugLogFlag = flag.String("debug-log", "/dev/null", "enable debug logging to file")
+	storageFlag  = flag.String("storage-dir", "", "use local storage in directory (instead of S3)")
 
 	// These are assigned during compilation using `-ldflags` (see: Makefile)
 	awsRegion                 string
@@ -118,9 +116,16 @@ func main() {
 	flag.Parse()
 
 	// Content repository
-	content = &storage.Repository{
-		Store:  s3.New(session.New(&aws.Config{Region: aws.String(awsRegion)})),
-		Bucket: s3StructuredContentBucket,
+	if *storageFlag != "" {
+		if content, err = storage.NewLocalRepository(*storageFlag, s3StructuredContentBucket); err != nil {
+			panic(fmt.Errorf("Unable to open local storage: %w", err))
+		}
+	} else {
+		var awsClients *common.AWSClients
+		if awsClients, err = common.NewAWSClients(common.AWSConfigFromEnv(awsRegion)); err != nil {
+			panic(fmt.Errorf("Unable to create AWS clients: %w", err))
+		}
+		content = &storage.Repository{Store: awsClients.S3(), Bucket: s3StructuredContentBucket}
 	}
 
 	// Logging
@@ -204,7 +209,7 @@ func main() {
 		} else {
 			log.Debug("Stored topics object to content repository (ID=%s)", id)
 
-			if err = topicsIndex.Update(node, topics); err != nil {
+			if _, err = topicsIndex.Update(node, topics); err != nil {
 				panic(fmt.Errorf("Failed to update topics search index: %w", err))
 			}
 
@@ -2,6 +2,8 @@ module github.com/wikimedia/phoenix/lambdas/fetch-changed
 
 go 1.14
 
+replace github.com/wikimedia/phoenix/common => ../../common
+
 require (
 	github.com/aws/aws-lambda-go v1.19.1
 	github.com/aws/aws-sdk-go v1.35.0
@@ -14,7 +14,6 @@ import (
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/wikimedia/phoenix/common"
 )
@@ -54,9 +53,13 @@ func getPage(msg *common.ChangeEvent) ([]byte, error) {
 }
 
 func handleRequest(ctx context.Context, event events.SNSEvent) {
-	s3client := s3.New(session.New(&aws.Config{
-		Region: aws.String(awsRegion),
-	}))
+	awsClients, err := common.NewAWSClients(common.AWSConfigFromEnv(awsRegion))
+	if err != nil {
+		log.Error("Unable to create AWS clients: %s", err)
+		return
+	}
+
+	s3client := awsClients.S3()
 
 	snsChangePub := common.NewChangeEventPublisher(awsAccount, awsRegion, snsTopic)
 
@@ -13,7 +13,6 @@ import (
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/sns"
 	"github.com/wikimedia/phoenix/common"
@@ -151,7 +150,12 @@ func handleRequest(ctx context.Context, event events.SNSEvent) {
 
 func init() {
 	// AWS S3 client obj
-	s3client = s3.New(session.New(&aws.Config{Region: aws.String(awsRegion)}))
+	awsClients, err := common.NewAWSClients(common.AWSConfigFromEnv(awsRegion))
+	if err != nil {
+		panic(err)
+	}
+
+	s3client = awsClients.S3()
 
 	// AWS SNS client obj
 	snsclient = common.NewChangeEventPublisher(awsAccount, awsRegion, snsTopic)
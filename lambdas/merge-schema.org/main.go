@@ -12,7 +12,6 @@ import (
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/wikimedia/phoenix/common"
 	"golang.org/x/net/html"
@@ -137,7 +136,12 @@ func handleRequest(ctx context.Context, event events.SNSEvent) {
 
 func init() {
 	// Initialize the AWS S3 client
-	s3client = s3.New(session.New(&aws.Config{Region: aws.String(awsRegion)}))
+	awsClients, err := common.NewAWSClients(common.AWSConfigFromEnv(awsRegion))
+	if err != nil {
+		panic(err)
+	}
+
+	s3client = awsClients.S3()
 
 	// Determine logging level
 	var level string = "ERROR"
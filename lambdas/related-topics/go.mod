@@ -2,10 +2,16 @@ module github.com/wikimedia/phoenix/lambdas/related-topics
 
 go 1.15
 
+replace (
+	github.com/wikimedia/phoenix/common => ../../common
+	github.com/wikimedia/phoenix/rosette => ../../rosette
+	github.com/wikimedia/phoenix/storage => ../../storage
+)
+
 require (
 	github.com/andybalholm/cascadia v1.2.0 // indirect
 	github.com/aws/aws-lambda-go v1.23.0
-	github.com/aws/aws-sdk-go v1.38.0
+	github.com/aws/aws-sdk-go v1.38.0 // indirect
 	github.com/elastic/go-elasticsearch/v7 v7.11.0
 	github.com/google/uuid v1.2.0 // indirect
 	github.com/stretchr/testify v1.7.0 // indirect
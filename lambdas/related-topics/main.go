@@ -3,13 +3,12 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/elastic/go-elasticsearch/v7"
 	"github.com/wikimedia/phoenix/common"
 	"github.com/wikimedia/phoenix/rosette"
@@ -33,7 +32,19 @@ var (
 	rosetteAPIKey             string
 )
 
-func handleRequest(ctx context.Context, event events.SNSEvent) {
+// Events that fail for reasons expected to clear on their own (storage throttling, or timeouts) fail the
+// invocation, so that Lambda retries it; Those that will never succeed are only logged.
+func handleRequest(ctx context.Context, event events.SNSEvent) error {
+	var retry []string
+
+	// Storage and search requests are abandoned once the invocation's deadline passes
+	var repo = content.WithContext(ctx)
+	var search = topicSearch
+
+	if s, ok := search.(storage.ContextTopicSearch); ok {
+		search = s.WithContext(ctx)
+	}
+
 	for _, record := range event.Records {
 		var err error
 		var msg = &common.NodeStoredEvent{}
@@ -49,8 +60,20 @@ func handleRequest(ctx context.Context, event events.SNSEvent) {
 		log.Debug("Processing Node published event: %+v", msg)
 
 		// Retrieve Node object from storage
-		if node, err = content.GetNode(msg.ID); err != nil {
-			log.Error("Failed to retreive S3 object for node (ID=%s): %s", msg.ID, err)
+		if node, err = repo.GetNode(msg.ID); err != nil {
+			var nerr *storage.ErrNotFound
+
+			switch {
+			case errors.As(err, &nerr):
+				// Removed since it was published (by a subsequent revision)
+				log.Warn("Skipping node (ID=%s); It no longer exists", msg.ID)
+			case storage.IsRetryable(err):
+				log.Warn("Failed to retreive S3 object for node (ID=%s) (will retry): %s", msg.ID, err)
+				retry = append(retry, msg.ID)
+			default:
+				log.Error("Failed to retreive S3 object for node (ID=%s): %s", msg.ID, err)
+			}
+
 			continue
 		}
 
@@ -63,18 +86,29 @@ func handleRequest(ctx context.Context, event events.SNSEvent) {
 		}
 
 		// Store related topics...
-		if err = content.PutTopics(node, topics); err != nil {
+		if err = repo.PutTopics(node, topics); err != nil {
+			if storage.IsRetryable(err) {
+				log.Warn("Failed to store related-topics (will retry): %s", err)
+				retry = append(retry, msg.ID)
+				continue
+			}
 			log.Error("Failed to store related-topics: %s", err)
 		} else {
 			// ...and then update topic index (if storage is successful)
 			var stats *storage.UpdateStats
-			if stats, err = topicSearch.Update(node, topics); err != nil {
+			if stats, err = search.Update(node, topics); err != nil {
 				log.Error("Failed to index related-topics: %s", err)
 			} else {
 				log.Debug("Indexing stats: +%v", stats)
 			}
 		}
 	}
+
+	if len(retry) > 0 {
+		return fmt.Errorf("unable to process %d of %d nodes (%v)", len(retry), len(event.Records), retry)
+	}
+
+	return nil
 }
 
 func init() {
@@ -92,14 +126,20 @@ func init() {
 	log.Debug("AWS region .......................: %s", awsRegion)
 	log.Debug("S3 structured content bucket .....: %s", s3StructuredContentBucket)
 
+	var awsClients *common.AWSClients
+	var err error
+
 	// AWS S3 client obj
+	if awsClients, err = common.NewAWSClients(common.AWSConfigFromEnv(awsRegion)); err != nil {
+		panic(err)
+	}
+
 	content = storage.Repository{
-		Store:  s3.New(session.New(&aws.Config{Region: aws.String(awsRegion)})),
+		Store:  awsClients.S3(),
 		Bucket: s3StructuredContentBucket,
 	}
 
 	// Elasticsearch topic indexer
-	var err error
 	var esConfig elasticsearch.Config
 	var esClient *elasticsearch.Client
 
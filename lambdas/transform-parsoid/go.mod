@@ -2,12 +2,16 @@ module transform-parsoid
 
 go 1.14
 
+replace (
+	github.com/wikimedia/phoenix/common => ../../common
+	github.com/wikimedia/phoenix/storage => ../../storage
+)
+
 require (
 	github.com/PuerkitoBio/goquery v1.6.0
 	github.com/andybalholm/cascadia v1.2.0 // indirect
 	github.com/aws/aws-lambda-go v1.20.0
 	github.com/aws/aws-sdk-go v1.36.8
-	github.com/elastic/go-elasticsearch/v7 v7.10.0 // indirect
 	github.com/wikimedia/phoenix/common v0.0.0-20201207205910-f0d114bb14a4
 	github.com/wikimedia/phoenix/storage v0.0.0-20201207205910-f0d114bb14a4
 	golang.org/x/net v0.0.0-20201209123823-ac852fbbde11 // indirect
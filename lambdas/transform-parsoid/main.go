@@ -3,17 +3,17 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/sns"
 	"github.com/wikimedia/phoenix/common"
@@ -34,6 +34,10 @@ var (
 	s3RawIncomeFolder         string
 	s3RawLinkedFolder         string
 	snsNodePublished          string
+	snsPagePublished          string
+
+	// When set, documents are stored to the local filesystem instead of S3 & DynamoDB
+	storageDir = os.Getenv("STORAGE_DIR")
 
 	debug bool = false
 	log   *common.Logger
@@ -43,8 +47,9 @@ func keyf(msg *common.ChangeEvent) string {
 	return fmt.Sprintf("%s/%s/%s-%d", s3RawIncomeFolder, msg.ServerName, msg.Title, msg.Revision)
 }
 
-func readLinkedData(s3client *s3.S3, msg *common.ChangeEvent) (*common.Thing, error) {
-	meta, err := s3client.GetObject(
+func readLinkedData(ctx context.Context, s3client *s3.S3, msg *common.ChangeEvent) (*common.Thing, error) {
+	meta, err := s3client.GetObjectWithContext(
+		ctx,
 		&s3.GetObjectInput{
 			Bucket: aws.String(s3RawBucket),
 			Key:    aws.String(fmt.Sprintf("%s/%s/%s-%d.json", s3RawLinkedFolder, msg.ServerName, msg.Title, msg.Revision)),
@@ -66,62 +71,69 @@ func readLinkedData(s3client *s3.S3, msg *common.ChangeEvent) (*common.Thing, er
 	return thing, nil
 }
 
-// A helper function that returns a PostPutNodeCallback function conditional on a env variable.
-func postPutNodeCallback(snsClient *sns.SNS) func(node common.Node) error {
-	var disabled = false
-
+// True unless disabled using an env variable.
+func publishNodeEvents() bool {
 	if env, ok := os.LookupEnv("DISABLE_PUT_NODE_CALLBACK"); ok {
 		if v, err := strconv.ParseBool(env); err == nil {
-			disabled = v
+			return !v
 		}
 	}
+	return true
+}
 
-	// If not disabled (read: if enabled), return a callback that will deliver a "node published" SNS message.
-	if !disabled {
-		return func(node common.Node) error {
-			var b []byte
-			var err error
-			var input *sns.PublishInput
-			var output *sns.PublishOutput
-
-			// JSON-encoded SNS message
-			if b, err = json.Marshal(common.NodeStoredEvent{ID: node.ID}); err != nil {
-				log.Error("Unable to marhsal SNS event to JSON: %s", err)
-				return fmt.Errorf("Unable to marshal SNS event to JSON: %w", err)
-			}
+// Returns the Publisher that delivers the events recorded to the outbox; With local storage, events are appended
+// to a file (events.ndjson) in the storage directory.
+func publisher(snsClient *sns.SNS) storage.Publisher {
+	if storageDir != "" {
+		return &storage.FilePublisher{Path: filepath.Join(storageDir, "events.ndjson")}
+	}
 
-			input = &sns.PublishInput{
-				Message:  aws.String(string(b)),
-				TopicArn: aws.String(fmt.Sprintf("arn:aws:sns:%s:%s:%s", awsRegion, awsAccount, snsNodePublished)),
-			}
+	return &storage.SNSPublisher{
+		Client: snsClient,
+		Topics: map[string]string{
+			storage.EventNodeStored: fmt.Sprintf("arn:aws:sns:%s:%s:%s", awsRegion, awsAccount, snsNodePublished),
+			storage.EventPageStored: fmt.Sprintf("arn:aws:sns:%s:%s:%s", awsRegion, awsAccount, snsPagePublished),
+		},
+	}
+}
 
-			// Publish to SNS
-			if output, err = snsClient.Publish(input); err != nil {
-				log.Error("Failed to publish SNS event: %s", err)
-				return fmt.Errorf("Failed to publish SNS event: %w", err)
-			}
+// Events that fail for reasons expected to clear on their own (storage throttling, or timeouts) fail the
+// invocation, so that Lambda retries it (updates are idempotent); Those that will never succeed are only logged.
+func handleRequest(ctx context.Context, event events.SNSEvent) error {
+	var repo *storage.Repository
+	var retry []string
+
+	awsClients, err := common.NewAWSClients(common.AWSConfigFromEnv(awsRegion))
+	if err != nil {
+		log.Error("Unable to create AWS clients: %s", err)
+		return err
+	}
 
-			log.Debug("Successfully published SNS message: %s (%s stored)", *output.MessageId, node.ID)
+	s3client := awsClients.S3()
+	snsClient := awsClients.SNS()
 
-			return nil
+	if storageDir != "" {
+		if repo, err = storage.NewLocalRepository(storageDir, s3StructuredContentBucket); err != nil {
+			log.Error("Unable to open local storage: %s", err)
+			return err
+		}
+	} else {
+		repo = &storage.Repository{
+			Store:  s3client,
+			Index:  &storage.DynamoDBIndex{Client: awsClients.DynamoDB(), TitlesTable: dynamoDBPageTitles, NamesTable: dynamoDBNodeNames},
+			Bucket: s3StructuredContentBucket,
 		}
 	}
 
-	// If disabled, return a no-op callback.
-	return func(node common.Node) error {
-		return nil
-	}
-}
+	// Storage requests are abandoned once the invocation's deadline passes
+	repo = repo.WithContext(ctx)
 
-func handleRequest(ctx context.Context, event events.SNSEvent) {
-	awsSession := session.New(&aws.Config{Region: aws.String(awsRegion)})
-	s3client := s3.New(awsSession)
-	snsClient := sns.New(awsSession)
+	// Record events for each page (and unless disabled, each node) published; These are delivered once the
+	// records are processed, and invalidate cached copies (see: service).
+	repo.Outbox = &storage.Outbox{Kinds: []string{storage.EventPageStored}}
 
-	repo := storage.Repository{
-		Store:  s3client,
-		Index:  &storage.DynamoDBIndex{Client: dynamodb.New(awsSession), TitlesTable: dynamoDBPageTitles, NamesTable: dynamoDBNodeNames},
-		Bucket: s3StructuredContentBucket,
+	if publishNodeEvents() {
+		repo.Outbox.Kinds = append(repo.Outbox.Kinds, storage.EventNodeStored)
 	}
 
 	for _, record := range event.Records {
@@ -133,7 +145,8 @@ func handleRequest(ctx context.Context, event events.SNSEvent) {
 
 		log.Debug("Processing change event: %+v", msg)
 
-		data, err := s3client.GetObject(
+		data, err := s3client.GetObjectWithContext(
+			ctx,
 			&s3.GetObjectInput{
 				Bucket: aws.String(s3RawBucket),
 				Key:    aws.String(keyf(msg)),
@@ -165,7 +178,7 @@ func handleRequest(ctx context.Context, event events.SNSEvent) {
 
 		log.Debug("Loading JSON-LD output from S3...")
 
-		thing, err := readLinkedData(s3client, msg)
+		thing, err := readLinkedData(ctx, s3client, msg)
 		if err != nil {
 			log.Error("Unable to load linked data with error: %s", err)
 			continue
@@ -173,22 +186,61 @@ func handleRequest(ctx context.Context, event events.SNSEvent) {
 
 		log.Debug("Saving document in canonical format...")
 
-		saveError := repo.Apply(&storage.Update{
+		result, saveError := repo.Apply(&storage.Update{
 			Page:   *page,
 			Nodes:  nodes,
 			Abouts: map[string]common.Thing{"//schema.org": *thing},
-
-			// Send events for each node published
-			PostPutNodeCallback: postPutNodeCallback(snsClient),
 		})
 
 		if saveError != nil {
-			log.Error("Unable to save to storage: %s", saveError)
+			var stale *storage.ErrStaleRevision
+
+			switch {
+			case errors.As(saveError, &stale):
+				// Change events are not delivered in order; An older revision arriving late is skipped.
+				log.Warn("Skipping out-of-order change event: %s", saveError)
+			case storage.IsRetryable(saveError):
+				log.Warn("Unable to save to storage (will retry): %s", saveError)
+				retry = append(retry, fmt.Sprintf("%s/%s", msg.ServerName, msg.Title))
+			default:
+				log.Error("Unable to save to storage: %s", saveError)
+			}
+
 			continue
 		}
 
-		log.Debug("Page saved successfully")
+		statuses := make(map[string]int)
+		for _, node := range result.Nodes {
+			statuses[node.Status]++
+		}
+
+		log.Debug(
+			"Page %s saved successfully (revision=%d, previous=%d, nodes added=%d, changed=%d, unchanged=%d, removed=%d, linked data written=%d, deleted=%d)",
+			result.PageID,
+			result.Revision,
+			result.PreviousRevision,
+			statuses[storage.NodeCreated],
+			statuses[storage.NodeUpdated],
+			statuses[storage.NodeUnchanged],
+			statuses[storage.NodeRemoved],
+			len(result.AboutsWritten),
+			len(result.AboutsDeleted))
 	}
+
+	// Events that cannot be delivered now are left in the outbox, for a subsequent invocation to deliver
+	relay := &storage.Relay{Repository: repo, Publisher: publisher(snsClient)}
+
+	if stats, err := relay.Run(); err != nil {
+		log.Error("Unable to relay events: %s", err)
+	} else {
+		log.Debug("Relayed events (delivered=%d, failed=%d, set aside=%d, discarded=%d)", stats.Delivered, stats.Failed, stats.SetAside, stats.Discarded)
+	}
+
+	if len(retry) > 0 {
+		return fmt.Errorf("unable to save %d of %d documents (%v)", len(retry), len(event.Records), retry)
+	}
+
+	return nil
 }
 
 func init() {
@@ -211,6 +263,8 @@ func init() {
 	log.Debug("S3 raw content incoming folder ...: %s", s3RawIncomeFolder)
 	log.Debug("S3 raw content linked folder .....: %s", s3RawLinkedFolder)
 	log.Debug("SNS node published topic .........: %s", snsNodePublished)
+	log.Debug("SNS page published topic .........: %s", snsPagePublished)
+	log.Debug("Local storage directory ..........: %s", storageDir)
 }
 
 func main() {
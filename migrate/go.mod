@@ -0,0 +1,13 @@
+module github.com/wikimedia/phoenix/migrate
+
+go 1.15
+
+replace (
+	github.com/wikimedia/phoenix/common => ../common
+	github.com/wikimedia/phoenix/storage => ../storage
+)
+
+require (
+	github.com/wikimedia/phoenix/common v0.0.0-20201207205910-f0d114bb14a4
+	github.com/wikimedia/phoenix/storage v0.0.0-00010101000000-000000000000
+)
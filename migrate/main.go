@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/wikimedia/phoenix/common"
+	"github.com/wikimedia/phoenix/storage"
+)
+
+var (
+	// Command-line flags
+	checkpointFlag = flag.String("checkpoint", "", "file to record progress to, and resume from")
+	dryRunFlag     = flag.Bool("dry-run", false, "count the objects in need of migration, without rewriting them")
+	storageFlag    = flag.String("storage-dir", "", "use local storage in directory (instead of S3)")
+
+	// These are assigned during compilation using `-ldflags` (see: Makefile)
+	awsRegion                 string
+	s3StructuredContentBucket string
+)
+
+func init() {
+	if awsRegion == "" {
+		panic("awsRegion is UNSET; not passed in ldflags during compilation!")
+	}
+	if s3StructuredContentBucket == "" {
+		panic("s3StructuredContentBucket is UNSET; not passed in ldflags during compilation!")
+	}
+}
+
+func main() {
+	var content *storage.Repository
+	var err error
+	var progress *storage.MigrateProgress
+	var resumed storage.MigrateProgress
+	var log = common.NewLogger("INFO")
+
+	flag.Parse()
+
+	log.SetFlags(common.Ltimestamp)
+
+	// Content repository
+	if *storageFlag != "" {
+		if content, err = storage.NewLocalRepository(*storageFlag, s3StructuredContentBucket); err != nil {
+			panic(fmt.Errorf("Unable to open local storage: %w", err))
+		}
+	} else {
+		var awsClients *common.AWSClients
+		if awsClients, err = common.NewAWSClients(common.AWSConfigFromEnv(awsRegion)); err != nil {
+			panic(fmt.Errorf("Unable to create AWS clients: %w", err))
+		}
+		content = &storage.Repository{Store: awsClients.S3(), Bucket: s3StructuredContentBucket}
+	}
+
+	if err = storage.ReadCheckpoint(*checkpointFlag, &resumed); err != nil {
+		panic(fmt.Errorf("Unable to read checkpoint: %w", err))
+	}
+
+	if resumed.Cursor != "" {
+		log.Info("Resuming after %s", resumed.Cursor)
+	}
+
+	progress, err = content.Migrate(storage.MigrateOptions{
+		Cursor: resumed.Cursor,
+		DryRun: *dryRunFlag,
+		Checkpoint: func(progress storage.MigrateProgress) error {
+			log.Info("%d objects examined, %d migrated; checkpoint %s", progress.Objects, progress.Migrated, progress.Cursor)
+			if *dryRunFlag {
+				return nil
+			}
+			return storage.WriteCheckpoint(*checkpointFlag, progress)
+		},
+	})
+
+	if err != nil {
+		log.Error("Migration failed (resume with the same -checkpoint): %s", err)
+		os.Exit(1)
+	}
+
+	if *dryRunFlag {
+		log.Info("Dry run complete: %d objects examined, %d in need of migration", progress.Objects, progress.Migrated)
+	} else {
+		log.Info("Migration complete: %d objects examined, %d migrated", progress.Objects, progress.Migrated)
+	}
+}
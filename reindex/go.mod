@@ -0,0 +1,16 @@
+module github.com/wikimedia/phoenix/reindex
+
+go 1.15
+
+replace (
+	github.com/wikimedia/phoenix/common => ../common
+	github.com/wikimedia/phoenix/storage => ../storage
+)
+
+require (
+	github.com/elastic/go-elasticsearch/v7 v7.10.0
+	github.com/lib/pq v1.9.0
+	github.com/mattn/go-sqlite3 v1.14.6
+	github.com/wikimedia/phoenix/common v0.0.0-20201207205910-f0d114bb14a4
+	github.com/wikimedia/phoenix/storage v0.0.0-00010101000000-000000000000
+)
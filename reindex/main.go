@@ -0,0 +1,143 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/elastic/go-elasticsearch/v7"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/wikimedia/phoenix/common"
+	"github.com/wikimedia/phoenix/storage"
+)
+
+var (
+	// Command-line flags
+	authorityFlag  = flag.String("authority", "", "only reindex the pages of this wiki")
+	batchSizeFlag  = flag.Int("batch-size", 1000, "number of pages indexed between checkpoints")
+	checkpointFlag = flag.String("checkpoint", "", "file to record progress to, and resume from")
+	storageFlag    = flag.String("storage-dir", "", "use local storage in directory (instead of S3)")
+	targetFlag     = flag.String("target", "dynamodb", "index to rebuild: dynamodb, elasticsearch, postgres, sqlite, or bolt")
+	targetDSNFlag  = flag.String("target-dsn", "", "database connection string (postgres), or file name (sqlite, bolt)")
+	esPageFlag     = flag.String("es-page-index", "page_name", "Elasticsearch index of page names")
+	esNodeFlag     = flag.String("es-node-index", "node_name", "Elasticsearch index of node names")
+
+	// These are assigned during compilation using `-ldflags` (see: Makefile)
+	awsRegion                 string
+	dynamoDBNodeNames         string
+	dynamoDBPageTitles        string
+	esEndpoint                string
+	esUsername                string
+	esPassword                string
+	s3StructuredContentBucket string
+)
+
+func init() {
+	if awsRegion == "" {
+		panic("awsRegion is UNSET; not passed in ldflags during compilation!")
+	}
+	if s3StructuredContentBucket == "" {
+		panic("s3StructuredContentBucket is UNSET; not passed in ldflags during compilation!")
+	}
+}
+
+// Returns the Index to be rebuilt, according to the command-line flags
+func openIndex(awsClients *common.AWSClients) (storage.Index, error) {
+	switch *targetFlag {
+	case "dynamodb":
+		return &storage.DynamoDBIndex{
+			Client:      awsClients.DynamoDB(),
+			TitlesTable: dynamoDBPageTitles,
+			NamesTable:  dynamoDBNodeNames,
+		}, nil
+
+	case "elasticsearch":
+		client, err := elasticsearch.NewClient(elasticsearch.Config{
+			Addresses: []string{esEndpoint},
+			Username:  esUsername,
+			Password:  esPassword,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Unable to create Elasticsearch client: %w", err)
+		}
+		return &storage.ElasticsearchIndex{Client: client, PageIndex: *esPageFlag, NodeIndex: *esNodeFlag}, nil
+
+	case "postgres", "sqlite":
+		driver := map[string]string{"postgres": "postgres", "sqlite": "sqlite3"}[*targetFlag]
+		if *targetDSNFlag == "" {
+			return nil, errors.New("-target-dsn is required")
+		}
+		db, err := sql.Open(driver, *targetDSNFlag)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to open database: %w", err)
+		}
+		return storage.NewSQLIndex(db)
+
+	case "bolt":
+		if *targetDSNFlag == "" {
+			return nil, errors.New("-target-dsn is required")
+		}
+		return storage.NewBoltIndex(*targetDSNFlag)
+	}
+
+	return nil, fmt.Errorf("Unknown target index: %s", *targetFlag)
+}
+
+func main() {
+	var awsClients *common.AWSClients
+	var content *storage.Repository
+	var err error
+	var index storage.Index
+	var progress *storage.ReindexProgress
+	var resumed storage.ReindexProgress
+	var log = common.NewLogger("INFO")
+
+	flag.Parse()
+
+	log.SetFlags(common.Ltimestamp)
+
+	if awsClients, err = common.NewAWSClients(common.AWSConfigFromEnv(awsRegion)); err != nil {
+		panic(fmt.Errorf("Unable to create AWS clients: %w", err))
+	}
+
+	// Content repository
+	if *storageFlag != "" {
+		if content, err = storage.NewLocalRepository(*storageFlag, s3StructuredContentBucket); err != nil {
+			panic(fmt.Errorf("Unable to open local storage: %w", err))
+		}
+	} else {
+		content = &storage.Repository{Store: awsClients.S3(), Bucket: s3StructuredContentBucket}
+	}
+
+	if index, err = openIndex(awsClients); err != nil {
+		panic(err)
+	}
+
+	if err = storage.ReadCheckpoint(*checkpointFlag, &resumed); err != nil {
+		panic(fmt.Errorf("Unable to read checkpoint: %w", err))
+	}
+
+	if resumed.Cursor != "" {
+		log.Info("Resuming after %s", resumed.Cursor)
+	}
+
+	progress, err = content.Reindex(index, storage.ReindexOptions{
+		Authority: *authorityFlag,
+		Cursor:    resumed.Cursor,
+		BatchSize: *batchSizeFlag,
+		Checkpoint: func(progress storage.ReindexProgress) error {
+			log.Info("%d pages, %d nodes indexed (%d missing); checkpoint %s", progress.Pages, progress.Nodes, progress.MissingNodes, progress.Cursor)
+			return storage.WriteCheckpoint(*checkpointFlag, progress)
+		},
+	})
+
+	if err != nil {
+		log.Error("Reindex failed (resume with the same -checkpoint): %s", err)
+		os.Exit(1)
+	}
+
+	log.Info("Reindex complete: %d pages, %d nodes indexed (%d missing)", progress.Pages, progress.Nodes, progress.MissingNodes)
+}
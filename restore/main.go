@@ -0,0 +1,161 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/elastic/go-elasticsearch/v7"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/wikimedia/phoenix/common"
+	"github.com/wikimedia/phoenix/storage"
+)
+
+var (
+	// Command-line flags
+	forceFlag       = flag.Bool("force", false, "overwrite pages whose stored revision is newer than that of the dump")
+	indexFlag       = flag.String("index", "", "index to update: dynamodb, elasticsearch, postgres, sqlite, or bolt (default dynamodb, or with -storage-dir, the local index)")
+	indexDSNFlag    = flag.String("index-dsn", "", "database connection string (postgres), or file name (sqlite, bolt)")
+	inputFlag       = flag.String("input", "-", "file to read the dump from (ndjson, or tar; optionally gzipped)")
+	storageFlag     = flag.String("storage-dir", "", "use local storage in directory (instead of S3)")
+	topicSearchFlag = flag.Bool("topic-search", false, "add related topics to the Elasticsearch topic search index")
+	esPageFlag      = flag.String("es-page-index", "page_name", "Elasticsearch index of page names")
+	esNodeFlag      = flag.String("es-node-index", "node_name", "Elasticsearch index of node names")
+
+	// These are assigned during compilation using `-ldflags` (see: Makefile)
+	awsRegion                 string
+	dynamoDBNodeNames         string
+	dynamoDBPageTitles        string
+	esEndpoint                string
+	esIndex                   string
+	esUsername                string
+	esPassword                string
+	s3StructuredContentBucket string
+)
+
+func init() {
+	if awsRegion == "" {
+		panic("awsRegion is UNSET; not passed in ldflags during compilation!")
+	}
+	if s3StructuredContentBucket == "" {
+		panic("s3StructuredContentBucket is UNSET; not passed in ldflags during compilation!")
+	}
+}
+
+func newElasticsearchClient() (*elasticsearch.Client, error) {
+	client, err := elasticsearch.NewClient(elasticsearch.Config{
+		Addresses: []string{esEndpoint},
+		Username:  esUsername,
+		Password:  esPassword,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create Elasticsearch client: %w", err)
+	}
+	return client, nil
+}
+
+// Returns the Index to be updated, according to the command-line flags
+func openIndex(awsClients *common.AWSClients) (storage.Index, error) {
+	switch *indexFlag {
+	case "dynamodb":
+		return &storage.DynamoDBIndex{
+			Client:      awsClients.DynamoDB(),
+			TitlesTable: dynamoDBPageTitles,
+			NamesTable:  dynamoDBNodeNames,
+		}, nil
+
+	case "elasticsearch":
+		client, err := newElasticsearchClient()
+		if err != nil {
+			return nil, err
+		}
+		return &storage.ElasticsearchIndex{Client: client, PageIndex: *esPageFlag, NodeIndex: *esNodeFlag}, nil
+
+	case "postgres", "sqlite":
+		driver := map[string]string{"postgres": "postgres", "sqlite": "sqlite3"}[*indexFlag]
+		if *indexDSNFlag == "" {
+			return nil, errors.New("-index-dsn is required")
+		}
+		db, err := sql.Open(driver, *indexDSNFlag)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to open database: %w", err)
+		}
+		return storage.NewSQLIndex(db)
+
+	case "bolt":
+		if *indexDSNFlag == "" {
+			return nil, errors.New("-index-dsn is required")
+		}
+		return storage.NewBoltIndex(*indexDSNFlag)
+	}
+
+	return nil, fmt.Errorf("Unknown index: %s", *indexFlag)
+}
+
+func main() {
+	var awsClients *common.AWSClients
+	var content *storage.Repository
+	var err error
+	var input io.ReadCloser = os.Stdin
+	var reader storage.DumpReader
+	var stats *storage.DumpStats
+
+	flag.Parse()
+
+	if awsClients, err = common.NewAWSClients(common.AWSConfigFromEnv(awsRegion)); err != nil {
+		panic(fmt.Errorf("Unable to create AWS clients: %w", err))
+	}
+
+	// Content repository
+	if *storageFlag != "" {
+		if content, err = storage.NewLocalRepository(*storageFlag, s3StructuredContentBucket); err != nil {
+			panic(fmt.Errorf("Unable to open local storage: %w", err))
+		}
+	} else {
+		content = &storage.Repository{Store: awsClients.S3(), Bucket: s3StructuredContentBucket}
+
+		if *indexFlag == "" {
+			*indexFlag = "dynamodb"
+		}
+	}
+
+	if *indexFlag != "" {
+		if content.Index, err = openIndex(awsClients); err != nil {
+			panic(err)
+		}
+	}
+
+	if *topicSearchFlag {
+		client, err := newElasticsearchClient()
+		if err != nil {
+			panic(err)
+		}
+		content.TopicSearch = &storage.ElasticTopicSearch{Client: client, IndexName: esIndex}
+	}
+
+	if *inputFlag != "-" {
+		if input, err = os.Open(*inputFlag); err != nil {
+			panic(fmt.Errorf("Unable to open input file: %w", err))
+		}
+	}
+
+	defer input.Close()
+
+	if reader, err = storage.NewDumpReader(input); err != nil {
+		panic(err)
+	}
+
+	if stats, err = content.Import(reader, storage.ImportOptions{Force: *forceFlag}); err != nil {
+		b, _ := json.Marshal(stats)
+		fmt.Fprintf(os.Stderr, "Restore failed (after %s): %s\n", b, err)
+		os.Exit(1)
+	}
+
+	b, _ := json.Marshal(stats)
+	fmt.Fprintf(os.Stderr, "Restore complete: %s\n", b)
+}
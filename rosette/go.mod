@@ -2,6 +2,8 @@ module github.com/wikimedia/phoenix/rosette
 
 go 1.15
 
+replace github.com/wikimedia/phoenix/common => ../common
+
 require (
 	github.com/PuerkitoBio/goquery v1.6.1
 	github.com/jpillora/backoff v1.0.0
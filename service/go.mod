@@ -2,6 +2,11 @@ module github.com/wikimedia/phoenix/service
 
 go 1.14
 
+replace (
+	github.com/wikimedia/phoenix/common => ../common
+	github.com/wikimedia/phoenix/storage => ../storage
+)
+
 require (
 	github.com/aws/aws-sdk-go v1.36.31
 	github.com/elastic/go-elasticsearch/v7 v7.10.0
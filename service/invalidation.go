@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/wikimedia/phoenix/common"
+	"github.com/wikimedia/phoenix/storage"
+)
+
+const (
+	// Delay before receiving again, after a failure to receive from the queue
+	invalidationRetryDelay = 5 * time.Second
+
+	// Interval at which the events file is checked for new events
+	invalidationPollInterval = time.Second
+)
+
+// Invalidator removes the cached copies of stored objects as the events of their updates are received (see:
+// storage.SNSPublisher, and storage.FilePublisher).  Events are JSON objects with the ID of the object stored;
+// Nodes and Pages are told apart by ID.
+type Invalidator struct {
+	Store  *storage.CachingStore
+	Bucket string
+	Logger *common.Logger
+}
+
+// Invalidates the cached copy of the object an event refers to
+func (i *Invalidator) handle(message []byte) error {
+	var event struct {
+		ID string `json:"id"`
+
+		// SNS notifications delivered to SQS are wrapped in an envelope (unless raw delivery is enabled)
+		Type    string `json:"Type"`
+		Message string `json:"Message"`
+	}
+
+	if err := json.Unmarshal(message, &event); err != nil {
+		return err
+	}
+
+	if event.Type == "Notification" {
+		if err := json.Unmarshal([]byte(event.Message), &event); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(event.ID, "/node/"):
+		return i.Store.InvalidateNode(i.Bucket, common.NodeStoredEvent{ID: event.ID})
+	case strings.HasPrefix(event.ID, "/page/"):
+		return i.Store.InvalidatePage(i.Bucket, common.PageStoredEvent{ID: event.ID})
+	}
+
+	return nil
+}
+
+// Receive receives events from an SQS queue (by name), indefinitely.  Events that fail to be handled are left
+// for redelivery.
+func (i *Invalidator) Receive(client sqsiface.SQSAPI, queue string) {
+	var output *sqs.GetQueueUrlOutput
+	var err error
+
+	for {
+		if output, err = client.GetQueueUrl(&sqs.GetQueueUrlInput{QueueName: aws.String(queue)}); err == nil {
+			break
+		}
+
+		i.Logger.Error("Unable to resolve cache invalidation queue %s: %s", queue, err)
+		time.Sleep(invalidationRetryDelay)
+	}
+
+	for {
+		var received *sqs.ReceiveMessageOutput
+
+		received, err = client.ReceiveMessage(
+			&sqs.ReceiveMessageInput{
+				QueueUrl:            output.QueueUrl,
+				MaxNumberOfMessages: aws.Int64(10),
+				WaitTimeSeconds:     aws.Int64(20),
+			})
+
+		if err != nil {
+			i.Logger.Error("Unable to receive cache invalidation events: %s", err)
+			time.Sleep(invalidationRetryDelay)
+			continue
+		}
+
+		for _, msg := range received.Messages {
+			if err = i.handle([]byte(aws.StringValue(msg.Body))); err != nil {
+				i.Logger.Error("Unable to invalidate cached object (message=%s): %s", aws.StringValue(msg.Body), err)
+				continue
+			}
+
+			if _, err = client.DeleteMessage(&sqs.DeleteMessageInput{QueueUrl: output.QueueUrl, ReceiptHandle: msg.ReceiptHandle}); err != nil {
+				i.Logger.Error("Unable to delete cache invalidation event: %s", err)
+			}
+		}
+	}
+}
+
+// Follow reads the events appended to a file (see: storage.FilePublisher), indefinitely.  Events appended before
+// Follow is called are skipped (nothing was cached before then).
+func (i *Invalidator) Follow(path string) {
+	var offset int64 = -1
+
+	for {
+		offset = i.readFrom(path, offset)
+		time.Sleep(invalidationPollInterval)
+	}
+}
+
+// Handles the events of a file that follow offset (or when offset is negative, none), and returns the offset
+// that follows the last complete event read.
+func (i *Invalidator) readFrom(path string, offset int64) int64 {
+	var f *os.File
+	var info os.FileInfo
+	var reader *bufio.Reader
+	var err error
+
+	if f, err = os.Open(path); err != nil {
+		// Events appended once the file is created are new
+		if os.IsNotExist(err) {
+			return 0
+		}
+		i.Logger.Error("Unable to read cache invalidation events: %s", err)
+		return offset
+	}
+
+	defer f.Close()
+
+	if info, err = f.Stat(); err != nil {
+		i.Logger.Error("Unable to read cache invalidation events: %s", err)
+		return offset
+	}
+
+	// Skip what is already there, or start over if the file was truncated
+	if offset < 0 {
+		return info.Size()
+	}
+
+	if info.Size() < offset {
+		offset = 0
+	}
+
+	if _, err = f.Seek(offset, io.SeekStart); err != nil {
+		i.Logger.Error("Unable to read cache invalidation events: %s", err)
+		return offset
+	}
+
+	reader = bufio.NewReader(f)
+
+	for {
+		var line []byte
+
+		// A partial line is read again once it is complete
+		if line, err = reader.ReadBytes('\n'); err != nil {
+			return offset
+		}
+
+		offset += int64(len(line))
+
+		if err = i.handle(line); err != nil {
+			i.Logger.Error("Unable to invalidate cached object (event=%s): %s", strings.TrimSpace(string(line)), err)
+		}
+	}
+}
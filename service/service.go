@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
@@ -9,14 +10,11 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
 	"sync/atomic"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/elastic/go-elasticsearch/v7"
 	"github.com/gorilla/handlers"
 	graphql "github.com/graph-gophers/graphql-go"
@@ -41,21 +39,11 @@ var (
 	s3Bucket           string
 	esEndpoint         string
 	esIndex            string
+	sqsInvalidation    string
 	esUsername         string
 	esPassword         string
 )
 
-// True if err is an awserr.Error, AND its code is s3.ErrCodeNoSuchKey, false otherwise.
-func isS3NotFound(err error) bool {
-	var s3err awserr.Error
-	if errors.As(err, &s3err) {
-		if s3err.Code() == s3.ErrCodeNoSuchKey {
-			return true
-		}
-	}
-	return false
-}
-
 func isErrNotFound(err error) bool {
 	var nerr *storage.ErrNotFound
 	if errors.As(err, &nerr) {
@@ -64,6 +52,47 @@ func isErrNotFound(err error) bool {
 	return false
 }
 
+// StorageError is a storage error, annotated with a code that clients can act upon (returned as a GraphQL error
+// extension): INVALID, CONFLICT, RATE_LIMITED, UNAVAILABLE (the latter two can be retried), or INTERNAL.
+type StorageError struct {
+	err  error
+	code string
+}
+
+func (e *StorageError) Error() string {
+	return e.err.Error()
+}
+
+func (e *StorageError) Unwrap() error {
+	return e.err
+}
+
+// Extensions returns the GraphQL error extensions of the error
+func (e *StorageError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.code}
+}
+
+// Returns err (an error returned by storage) as a StorageError.
+func storageError(err error) error {
+	var code = "INTERNAL"
+	var ierr *storage.ErrInvalid
+	var cerr *storage.ErrConflict
+	var qerr *storage.ErrQuota
+
+	switch {
+	case errors.As(err, &ierr):
+		code = "INVALID"
+	case errors.As(err, &cerr):
+		code = "CONFLICT"
+	case errors.As(err, &qerr):
+		code = "RATE_LIMITED"
+	case storage.IsRetryable(err):
+		code = "UNAVAILABLE"
+	}
+
+	return &StorageError{err: err, code: code}
+}
+
 // PageNameInput corresponds to a GraphQL input used by the Page query
 type PageNameInput struct {
 	Authority string
@@ -84,24 +113,25 @@ type RootResolver struct {
 	Logger      *common.Logger
 }
 
-// Page returns a Page given its ID
-func (r *RootResolver) Page(args struct {
+// Page returns a Page given its ID.  Storage requests are abandoned if the client disconnects (ctx is cancelled).
+func (r *RootResolver) Page(ctx context.Context, args struct {
 	ID   *string
 	Name *PageNameInput
 }) (*PageResolver, error) {
 	var page *common.Page
+	var repo = r.Repository.WithContext(ctx)
 	var err error
 
 	if args.Name != nil {
 		// A page name was supplied
-		if page, err = r.Repository.GetPageByName(args.Name.Authority, args.Name.Name); err != nil {
+		if page, err = repo.GetPageByName(args.Name.Authority, args.Name.Name); err != nil {
 			// If err is of type storage.ErrNotFound, then this is not an error per say
 			if isErrNotFound(err) {
 				return nil, nil
 			}
 
 			r.Logger.Error("Unable to retrieve Page (authority=%s, name=%s): %s", args.Name.Authority, args.Name.Name, err)
-			return nil, err
+			return nil, storageError(err)
 		}
 
 		// A name argument was supplied and a matching page was found.  If an ID was also specified but it does NOT match
@@ -113,85 +143,90 @@ func (r *RootResolver) Page(args struct {
 		}
 	} else if args.ID != nil {
 		// The page ID was supplied
-		if page, err = r.Repository.GetPage(*args.ID); err != nil {
-			// If this was an error returned by S3 (it is an awserr.Error), and its code is s3.ErrCodeNoSuchKey
-			// then the object was simply not found (read: this is not an error per say).
-			if isS3NotFound(err) {
+		if page, err = repo.GetPage(*args.ID); err != nil {
+			// If err is of type storage.ErrNotFound, then this is not an error per say
+			if isErrNotFound(err) {
 				return nil, nil
 			}
 
 			r.Logger.Error("Unable to retrieve Page (ID=%s): %s", *args.ID, err)
-			return nil, err
+			return nil, storageError(err)
 		}
 	} else {
 		// Neither a page ID or a name was supplied
 		return nil, nil
 	}
 
-	return &PageResolver{page, r.Repository, recursionDepth}, nil
+	return &PageResolver{page, repo, recursionDepth}, nil
 }
 
-// Node returns a Node given its ID
-func (r *RootResolver) Node(args struct {
+// Node returns a Node given its ID.  Storage requests are abandoned if the client disconnects (ctx is cancelled).
+func (r *RootResolver) Node(ctx context.Context, args struct {
 	ID   *string
 	Name *NodeNameInput
 }) (*NodeResolver, error) {
 	var node *common.Node
+	var repo = r.Repository.WithContext(ctx)
 	var err error
 
 	if args.Name != nil {
-		if node, err = r.Repository.GetNodeByName(args.Name.Authority, args.Name.PageName, args.Name.Name); err != nil {
+		if node, err = repo.GetNodeByName(args.Name.Authority, args.Name.PageName, args.Name.Name); err != nil {
 			// If err is of type storage.ErrNotFound, then this is not an error per say
 			if isErrNotFound(err) {
 				return nil, nil
 			}
 
 			r.Logger.Error("Unable to retrieve Node (authority=%s, pageName=%s, name=%s): %s", args.Name.Authority, args.Name.PageName, args.Name.Name, err)
-			return nil, err
+			return nil, storageError(err)
 		}
 	} else if args.ID != nil {
-		if node, err = r.Repository.GetNode(*args.ID); err != nil {
-			// If this was an error returned by S3 (it is an awserr.Error) and its code is s3.ErrCodeNoSuchKey
-			// then the object was simply not found (read: this is not an error per say).
-			if isS3NotFound(err) {
+		if node, err = repo.GetNode(*args.ID); err != nil {
+			// If err is of type storage.ErrNotFound, then this is not an error per say
+			if isErrNotFound(err) {
 				return nil, nil
 			}
 
 			r.Logger.Error("Unable to retrieve Node (ID=%s): %s", *args.ID, err)
-			return nil, err
+			return nil, storageError(err)
 		}
 	} else {
 		// Neither a node ID or a name was supplied
 		return nil, nil
 	}
 
-	return &NodeResolver{node, r.Repository, recursionDepth}, nil
+	return &NodeResolver{node, repo, recursionDepth}, nil
 }
 
 // Nodes returns relevant Nodes for a given predicate (currently only Wikidata topic ID)
-func (r *RootResolver) Nodes(args struct{ Keyword *string }) ([]*NodeResolver, error) {
+func (r *RootResolver) Nodes(ctx context.Context, args struct{ Keyword *string }) ([]*NodeResolver, error) {
 	var err error
 	var nodes []string
+	var repo = r.Repository.WithContext(ctx)
 	var resolvers = make([]*NodeResolver, 0)
+	var search = r.TopicSearch
 
-	if nodes, err = r.TopicSearch.Search(*args.Keyword); err != nil {
+	if s, ok := search.(storage.ContextTopicSearch); ok {
+		search = s.WithContext(ctx)
+	}
+
+	if nodes, err = search.Search(*args.Keyword); err != nil {
 		return nil, fmt.Errorf("Topic search failed: %w", err)
 	}
 
-	var node *common.Node
+	var found []*common.Node
 
-	for _, nid := range nodes {
-		r.Logger.Info("Found node %s", nid)
-		if node, err = r.Repository.GetNode(nid); err != nil {
-			// If this was an error returned by S3 (it is an awserr.Error) and its code is s3.ErrCodeNoSuchKey
-			// then the object was simply not found (read: this is not an error per say).
-			if isS3NotFound(err) {
-				r.Logger.Warn("Lookup of %s failed!", nid)
-				continue
-			}
-			return nil, err
+	if found, err = repo.GetNodes(nodes); err != nil {
+		return nil, storageError(err)
+	}
+
+	for i, node := range found {
+		// A node that was not found is not an error per say (the search index can lag storage)
+		if node == nil {
+			r.Logger.Warn("Lookup of %s failed!", nodes[i])
+			continue
 		}
-		resolvers = append(resolvers, &NodeResolver{node, r.Repository, recursionDepth})
+		r.Logger.Info("Found node %s", nodes[i])
+		resolvers = append(resolvers, &NodeResolver{node, repo, recursionDepth})
 	}
 
 	return resolvers, nil
@@ -230,7 +265,8 @@ func (r *PageResolver) HasPart(args struct {
 	Offset *int32
 }) ([]*NodeResolver, error) {
 	var err error
-	var node *common.Node
+	var ids []string
+	var nodes []*common.Node
 	var offset int32 = 0
 	var resolvers = make([]*NodeResolver, 0)
 
@@ -245,18 +281,27 @@ func (r *PageResolver) HasPart(args struct {
 		return nil, fmt.Errorf("max recursion reached")
 	}
 
-	// TODO: This is slow; Consider adding concurrency
-	for i, id := range r.p.HasPart[offset:] {
-		if args.Limit != nil && (int32(i)+1) > *args.Limit {
-			break
+	if offset < 0 || int(offset) >= len(r.p.HasPart) {
+		return resolvers, nil
+	}
+
+	ids = r.p.HasPart[offset:]
+
+	if args.Limit != nil && int(*args.Limit) < len(ids) {
+		if *args.Limit < 0 {
+			return resolvers, nil
 		}
-		if node, err = r.repo.GetNode(id); err != nil {
-			// If this was an error returned by S3 (it is an awserr.Error) and its code is s3.ErrCodeNoSuchKey
-			// then the object was simply not found (read: this is not an error per say).
-			if isS3NotFound(err) {
-				return nil, nil
-			}
-			return nil, err
+		ids = ids[:*args.Limit]
+	}
+
+	if nodes, err = r.repo.GetNodes(ids); err != nil {
+		return nil, storageError(err)
+	}
+
+	for _, node := range nodes {
+		// A node that was not found is not an error per say; Skip it.
+		if node == nil {
+			continue
 		}
 		resolvers = append(resolvers, &NodeResolver{node, r.repo, r.recurse})
 	}
@@ -319,7 +364,7 @@ func (r *NodeResolver) Name() string {
 // IsPartOf resolves a page for the node's isPartOf ID
 func (r *NodeResolver) IsPartOf() ([]*PageResolver, error) {
 	var err error
-	var page *common.Page
+	var pages []*common.Page
 	var parents = make([]*PageResolver, 0)
 
 	// Decrement the recursion counter
@@ -329,18 +374,18 @@ func (r *NodeResolver) IsPartOf() ([]*PageResolver, error) {
 		return nil, fmt.Errorf("max recursion reached")
 	}
 
-	// TODO: This is slow; Consider adding concurrency
-	for _, id := range r.n.IsPartOf {
-		if page, err = r.repo.GetPage(id); err != nil {
-			// If this was an error returned by S3 (it is an awserr.Error) and its code is s3.ErrCodeNoSuchKey
-			// then the object was simply not found (read: this is not an error per say).
-			if isS3NotFound(err) {
-				return nil, nil
-			}
-			return nil, err
+	if pages, err = r.repo.GetPages(r.n.IsPartOf); err != nil {
+		return nil, storageError(err)
+	}
+
+	for _, page := range pages {
+		// A page that was not found is not an error per say; Skip it.
+		if page == nil {
+			continue
 		}
 		parents = append(parents, &PageResolver{page, r.repo, r.recurse})
 	}
+
 	return parents, nil
 }
 
@@ -374,7 +419,7 @@ func (r *NodeResolver) Keywords(args struct {
 		if errors.As(err, &notFound) {
 			return resolvers, nil
 		}
-		return nil, err
+		return nil, storageError(err)
 	}
 
 	for i, topic := range topics[offset:] {
@@ -411,10 +456,20 @@ func (r *TopicResolver) Salience() float64 {
 }
 
 type config struct {
-	Region      string
+	// AWS region, endpoints, and credentials (see: common.AWSConfigFromEnv)
+	AWS common.AWSConfig
+
 	TitlesTable string
 	NamesTable  string
 	Bucket      string
+	StorageDir  string
+
+	// Read-through caching of stored objects (disabled when CacheSize is zero)
+	CacheSize int
+	CacheTTL  time.Duration
+
+	// Name of the SQS queue from which cache invalidation events are received (see: Invalidator)
+	InvalidationQueue string
 
 	ElasticSearch struct {
 		Endpoint string
@@ -436,10 +491,21 @@ func getConfig() config {
 
 	var cfg = config{}
 
-	cfg.Region = env("AWS_REGION", awsRegion)
+	cfg.AWS = common.AWSConfigFromEnv(env("AWS_REGION", awsRegion))
 	cfg.TitlesTable = env("AWS_DYNAMODB_PAGE_TITLES_TABLE", dynamoDBPageTitles)
 	cfg.NamesTable = env("AWS_DYNAMODB_NODE_NAMES_TABLE", dynamoDBNodeNames)
 	cfg.Bucket = env("AWS_BUCKET", s3Bucket)
+	cfg.StorageDir = env("STORAGE_DIR", "")
+
+	// Malformed values disable caching
+	if v, err := strconv.Atoi(env("CACHE_SIZE", "67108864")); err == nil {
+		cfg.CacheSize = v
+	}
+	if v, err := time.ParseDuration(env("CACHE_TTL", "1m")); err == nil {
+		cfg.CacheTTL = v
+	}
+
+	cfg.InvalidationQueue = env("CACHE_INVALIDATION_QUEUE", sqsInvalidation)
 
 	cfg.ElasticSearch.Endpoint = env("ES_ENDPOINT", esEndpoint)
 	cfg.ElasticSearch.Index = env("ES_INDEX", esIndex)
@@ -456,9 +522,10 @@ func main() {
 	var err error
 	var esClient *elasticsearch.Client
 	var logger *common.Logger
+	var repository *storage.Repository
 	var resolver *RootResolver
 	var schema *graphql.Schema
-	var awsSession = session.New(&aws.Config{Region: aws.String(cfg.Region)})
+	var awsClients *common.AWSClients
 
 	flag.Parse()
 
@@ -497,12 +564,42 @@ func main() {
 		os.Exit(1)
 	}
 
-	resolver = &RootResolver{
-		Repository: &storage.Repository{
-			Store:  s3.New(awsSession),
-			Index:  &storage.DynamoDBIndex{Client: dynamodb.New(awsSession), TitlesTable: cfg.TitlesTable, NamesTable: cfg.NamesTable},
+	if awsClients, err = common.NewAWSClients(cfg.AWS); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to create AWS clients: %s", err)
+		os.Exit(1)
+	}
+
+	// Use local (filesystem) storage if so configured, S3 & DynamoDB otherwise
+	if cfg.StorageDir != "" {
+		if repository, err = storage.NewLocalRepository(cfg.StorageDir, cfg.Bucket); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to open local storage: %s", err)
+			os.Exit(1)
+		}
+	} else {
+		repository = &storage.Repository{
+			Store:  awsClients.S3(),
+			Index:  &storage.DynamoDBIndex{Client: awsClients.DynamoDB(), TitlesTable: cfg.TitlesTable, NamesTable: cfg.NamesTable},
 			Bucket: cfg.Bucket,
-		},
+		}
+	}
+
+	// Objects are otherwise fetched from storage once per reference, per request
+	if cfg.CacheSize > 0 && cfg.CacheTTL > 0 {
+		cache := storage.NewCachingStore(repository.Store, cfg.CacheSize, cfg.CacheTTL)
+		repository.Store = cache
+
+		// Cached copies of updated objects are invalidated by the events of the update (or failing that, expire)
+		invalidator := &Invalidator{Store: cache, Bucket: cfg.Bucket, Logger: logger}
+
+		if cfg.StorageDir != "" {
+			go invalidator.Follow(filepath.Join(cfg.StorageDir, "events.ndjson"))
+		} else if cfg.InvalidationQueue != "" {
+			go invalidator.Receive(awsClients.SQS(), cfg.InvalidationQueue)
+		}
+	}
+
+	resolver = &RootResolver{
+		Repository:  repository,
 		TopicSearch: &storage.ElasticTopicSearch{Client: esClient, IndexName: cfg.ElasticSearch.Index},
 		Logger:      logger,
 	}
@@ -0,0 +1,217 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// BoltDB bucket names
+	boltTitlesBucket = []byte("titles")
+	boltNamesBucket  = []byte("names")
+
+	// How long an operation waits on the lock of an index in use by another (writing) BoltIndex, or process
+	boltLockTimeout = 10 * time.Second
+)
+
+// BoltIndex is a Phoenix document indexer backed by a local BoltDB file.  BoltDB locks the file for as long as
+// it is open; A BoltIndex only opens it for the duration of each operation (reads sharing the lock, and writes
+// holding it exclusively), so that any number of BoltIndexes, in any number of processes (the service, and an
+// import into the same storage directory, for example), can use the same file.
+type BoltIndex struct {
+	path string
+}
+
+// NewBoltIndex returns a BoltIndex of the file at path (which is created if necessary).
+func NewBoltIndex(path string) (*BoltIndex, error) {
+	var index = &BoltIndex{path: path}
+
+	err := index.update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltTitlesBucket, boltNamesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize %s: %w", path, err)
+	}
+
+	return index, nil
+}
+
+// Apply updates the index with new Phoenix document data
+func (i *BoltIndex) Apply(update *Update) error {
+	page := update.Page
+
+	if err := validateNodeNames(update); err != nil {
+		return err
+	}
+
+	return i.update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(boltTitlesBucket).Put(boltTitleKey(page.Source.Authority, page.Name), []byte(page.ID)); err != nil {
+			return err
+		}
+
+		names := tx.Bucket(boltNamesBucket)
+
+		for _, n := range update.Nodes {
+			if err := names.Put(boltNameKey(n.Source.Authority, page.Name, n.Name), []byte(n.ID)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// PageIDForName queries the index for page ID matching authority (wiki) and name
+func (i *BoltIndex) PageIDForName(authority, name string) (string, error) {
+	var id string
+	var err error
+
+	if id, err = i.get(boltTitlesBucket, boltTitleKey(authority, name)); err != nil {
+		return "", err
+	}
+
+	if id == "" {
+		return "", &ErrNotFound{fmt.Sprintf("page index: %s/%s not found", authority, name)}
+	}
+
+	return id, nil
+}
+
+// NodeIDForName queries the index for node ID matching authority (wiki), page name, and name
+func (i *BoltIndex) NodeIDForName(authority, pageName, name string) (string, error) {
+	var id string
+	var err error
+
+	if id, err = i.get(boltNamesBucket, boltNameKey(authority, pageName, name)); err != nil {
+		return "", err
+	}
+
+	if id == "" {
+		return "", &ErrNotFound{fmt.Sprintf("node index: %s/%s/%s not found", authority, pageName, name)}
+	}
+
+	return id, nil
+}
+
+// RemovePage removes the index entry for a page name
+func (i *BoltIndex) RemovePage(authority, name string) error {
+	return i.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltTitlesBucket).Delete(boltTitleKey(authority, name))
+	})
+}
+
+// RemoveNode removes the index entry for a node name
+func (i *BoltIndex) RemoveNode(authority, pageName, name string) error {
+	return i.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltNamesBucket).Delete(boltNameKey(authority, pageName, name))
+	})
+}
+
+// ScanPages invokes f for each page name entry
+func (i *BoltIndex) ScanPages(f func(authority, name, id string) error) error {
+	return i.scan(boltTitlesBucket, func(authority, name, id string) error {
+		return f(authority, name, id)
+	})
+}
+
+// ScanNodes invokes f for each node name entry
+func (i *BoltIndex) ScanNodes(f func(authority, pageName, name, id string) error) error {
+	return i.scan(boltNamesBucket, func(authority, encoded, id string) error {
+		pageName, name, err := decodeNodeName(encoded)
+		if err != nil {
+			return err
+		}
+		return f(authority, pageName, name, id)
+	})
+}
+
+// Invokes f for each key of bucket (split into authority and name).  The callback is invoked outside of the
+// read transaction, so that it may itself modify the index.
+func (i *BoltIndex) scan(bucket []byte, f func(authority, name, id string) error) error {
+	var entries [][3]string
+
+	err := i.view(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).ForEach(func(k, v []byte) error {
+			parts := strings.SplitN(string(k), ":", 2)
+			if len(parts) != 2 {
+				return fmt.Errorf("malformed index key: %s", k)
+			}
+			entries = append(entries, [3]string{parts[0], parts[1], string(v)})
+			return nil
+		})
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if err = f(e[0], e[1], e[2]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Returns the value of key in bucket, or an empty string if no such key exists.
+func (i *BoltIndex) get(bucket, key []byte) (string, error) {
+	var value string
+
+	err := i.view(func(tx *bolt.Tx) error {
+		// Values returned by Get are only valid for the life of the transaction; Copy.
+		value = string(tx.Bucket(bucket).Get(key))
+		return nil
+	})
+
+	return value, err
+}
+
+// Runs f in a read-write transaction, holding the file's lock exclusively
+func (i *BoltIndex) update(f func(tx *bolt.Tx) error) error {
+	return i.open(false, func(db *bolt.DB) error { return db.Update(f) })
+}
+
+// Runs f in a read-only transaction, sharing the file's lock with other readers
+func (i *BoltIndex) view(f func(tx *bolt.Tx) error) error {
+	return i.open(true, func(db *bolt.DB) error { return db.View(f) })
+}
+
+// Opens the database file for the duration of f
+func (i *BoltIndex) open(readOnly bool, f func(db *bolt.DB) error) error {
+	var db *bolt.DB
+	var err error
+
+	if db, err = bolt.Open(i.path, 0644, &bolt.Options{Timeout: boltLockTimeout, ReadOnly: readOnly}); err != nil {
+		if errors.Is(err, bolt.ErrTimeout) {
+			return &ErrConflict{message: fmt.Sprintf("unable to open %s: locked (in use elsewhere)", i.path), err: err}
+		}
+		return fmt.Errorf("unable to open %s: %w", i.path, err)
+	}
+
+	if err = f(db); err != nil {
+		db.Close()
+		return err
+	}
+
+	return db.Close()
+}
+
+// Authorities (hostnames) never contain a colon, so prefixing keys with one is unambiguous.
+func boltTitleKey(authority, name string) []byte {
+	return []byte(fmt.Sprintf("%s:%s", authority, name))
+}
+
+func boltNameKey(authority, pageName, name string) []byte {
+	return []byte(fmt.Sprintf("%s:%s", authority, encodeNodeName(pageName, name)))
+}
@@ -0,0 +1,371 @@
+package storage
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/wikimedia/phoenix/common"
+)
+
+// Cache is a mockable interface for key/value caches of stored objects.  A value that is missing, or that has
+// expired, is reported as not found (ok == false) rather than as an error.
+type Cache interface {
+	Get(key string) (value []byte, ok bool, err error)
+	Set(key string, value []byte, ttl time.Duration) error
+	Delete(key string) error
+}
+
+// LRUCache is an in-process Cache, bounded by the total size of the keys and values it holds.  Once full, the
+// least recently used entries are evicted to make room.
+type LRUCache struct {
+	maxBytes int
+	size     int
+	entries  *list.List
+	index    map[string]*list.Element
+	mutex    sync.Mutex
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewLRUCache returns an LRUCache holding at most maxBytes of keys and values.
+func NewLRUCache(maxBytes int) *LRUCache {
+	return &LRUCache{maxBytes: maxBytes, entries: list.New(), index: make(map[string]*list.Element)}
+}
+
+// Get returns the value of a key
+func (c *LRUCache) Get(key string) ([]byte, bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	elem, ok := c.index[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	entry := elem.Value.(*lruEntry)
+
+	if time.Now().After(entry.expires) {
+		c.remove(elem)
+		return nil, false, nil
+	}
+
+	c.entries.MoveToFront(elem)
+
+	return entry.value, true, nil
+}
+
+// Set assigns the value of a key, to expire after ttl.  Values larger than the cache itself are not stored.
+func (c *LRUCache) Set(key string, value []byte, ttl time.Duration) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if elem, ok := c.index[key]; ok {
+		c.remove(elem)
+	}
+
+	if len(key)+len(value) > c.maxBytes {
+		return nil
+	}
+
+	c.index[key] = c.entries.PushFront(&lruEntry{key: key, value: value, expires: time.Now().Add(ttl)})
+	c.size += len(key) + len(value)
+
+	for c.size > c.maxBytes {
+		c.remove(c.entries.Back())
+	}
+
+	return nil
+}
+
+// Delete removes a key
+func (c *LRUCache) Delete(key string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if elem, ok := c.index[key]; ok {
+		c.remove(elem)
+	}
+
+	return nil
+}
+
+// Len returns the number of entries in the cache (including any that have expired, but have yet to be evicted).
+func (c *LRUCache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.entries.Len()
+}
+
+func (c *LRUCache) remove(elem *list.Element) {
+	entry := c.entries.Remove(elem).(*lruEntry)
+	delete(c.index, entry.key)
+	c.size -= len(entry.key) + len(entry.value)
+}
+
+// MemoryCache is an unbounded Cache kept in memory.  It is a local stand-in for a shared cache (one
+// that would otherwise be provided by a network service) for use in development and testing.
+type MemoryCache struct {
+	entries map[string]lruEntry
+	mutex   sync.Mutex
+}
+
+// NewMemoryCache returns an initialized MemoryCache
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: make(map[string]lruEntry)}
+}
+
+// Get returns the value of a key
+func (c *MemoryCache) Get(key string) ([]byte, bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return nil, false, nil
+	}
+
+	return entry.value, true, nil
+}
+
+// Set assigns the value of a key, to expire after ttl
+func (c *MemoryCache) Set(key string, value []byte, ttl time.Duration) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.entries[key] = lruEntry{key: key, value: value, expires: time.Now().Add(ttl)}
+
+	return nil
+}
+
+// Delete removes a key
+func (c *MemoryCache) Delete(key string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.entries, key)
+
+	return nil
+}
+
+// CachingStore is a read-through caching decorator for a Store.  Objects are cached first in-process (Local),
+// and then optionally in a cache shared between processes (Shared).  Writes and deletes made through a
+// CachingStore invalidate the corresponding entries; Changes made elsewhere (by other processes) are
+// propagated using the Invalidate methods, or failing that, once entries expire (TTL).
+//
+// Since only the Shared cache can be invalidated on behalf of other processes, TTL bounds how stale an
+// entry in the Local cache of another process can be.
+type CachingStore struct {
+	Store  Store
+	Local  Cache
+	Shared Cache
+	TTL    time.Duration
+}
+
+// WithContext returns a copy of the CachingStore whose (Store) requests are made with ctx.  The caches are
+// shared with the CachingStore.
+func (s *CachingStore) WithContext(ctx context.Context) Store {
+	var store = *s
+	store.Store = storeWithContext(s.Store, ctx)
+	return &store
+}
+
+// A cached object, as serialized to the caches
+type cachedObject struct {
+	Body         []byte             `json:"body"`
+	ContentType  string             `json:"contentType,omitempty"`
+	LastModified *time.Time         `json:"lastModified,omitempty"`
+	Metadata     map[string]*string `json:"metadata,omitempty"`
+}
+
+// NewCachingStore returns a CachingStore for store, caching up to maxBytes in-process, and holding entries
+// for at most ttl.
+func NewCachingStore(store Store, maxBytes int, ttl time.Duration) *CachingStore {
+	return &CachingStore{Store: store, Local: NewLRUCache(maxBytes), TTL: ttl}
+}
+
+// GetObject retrieves an object (see: s3.S3#GetObject), from cache if possible.  Errors (including those of
+// objects not found) are never cached.
+func (s *CachingStore) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	var b []byte
+	var ok bool
+	var obj cachedObject
+	var output *s3.GetObjectOutput
+	var key = cacheKey(input.Bucket, input.Key)
+	var generation = cacheGeneration(key)
+	var err error
+
+	// Cache lookup; A failing cache is treated as a miss, the Store remains authoritative.
+	for _, cache := range s.caches() {
+		if b, ok, err = cache.Get(key); err == nil && ok {
+			if err = json.Unmarshal(b, &obj); err == nil {
+				// Populate the local cache from the shared one
+				if cache != s.Local && s.Local != nil {
+					s.Local.Set(key, b, s.TTL)
+				}
+				return obj.output(), nil
+			}
+		}
+	}
+
+	// Writes made while the object is fetched bump the generation of its key (see: cacheGeneration)
+	fill := atomic.LoadUint64(generation)
+
+	if output, err = s.Store.GetObject(input); err != nil {
+		return nil, err
+	}
+
+	defer output.Body.Close()
+
+	if obj.Body, err = ioutil.ReadAll(output.Body); err != nil {
+		return nil, fmt.Errorf("unable to read object body: %w", err)
+	}
+
+	obj.ContentType = aws.StringValue(output.ContentType)
+	obj.LastModified = output.LastModified
+	obj.Metadata = output.Metadata
+
+	if b, err = json.Marshal(&obj); err != nil {
+		return nil, fmt.Errorf("unable to serialize cache entry: %w", err)
+	}
+
+	for _, cache := range s.caches() {
+		// Caching is best-effort
+		cache.Set(key, b, s.TTL)
+	}
+
+	// The object may have been overwritten (or deleted) since it was fetched, and its invalidation may have
+	// preceded the entries just set; These are removed, rather than risk caching a stale copy.
+	if atomic.LoadUint64(generation) != fill {
+		s.invalidate(key)
+	}
+
+	return obj.output(), nil
+}
+
+// PutObject stores an object (see: s3.S3#PutObject), invalidating any cached copy.
+func (s *CachingStore) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	var output *s3.PutObjectOutput
+	var err error
+
+	if output, err = s.Store.PutObject(input); err != nil {
+		return nil, err
+	}
+
+	key := cacheKey(input.Bucket, input.Key)
+	atomic.AddUint64(cacheGeneration(key), 1)
+
+	return output, s.invalidate(key)
+}
+
+// DeleteObjects removes objects (see: s3.S3#DeleteObjects), invalidating any cached copies.
+func (s *CachingStore) DeleteObjects(input *s3.DeleteObjectsInput) (*s3.DeleteObjectsOutput, error) {
+	var output *s3.DeleteObjectsOutput
+	var err error
+
+	// Invalidate regardless of the outcome; Some objects may have been removed despite an error.
+	output, err = s.Store.DeleteObjects(input)
+
+	for _, object := range input.Delete.Objects {
+		key := cacheKey(input.Bucket, object.Key)
+		atomic.AddUint64(cacheGeneration(key), 1)
+
+		if ierr := s.invalidate(key); ierr != nil && err == nil {
+			err = ierr
+		}
+	}
+
+	return output, err
+}
+
+// ListObjectsV2 lists objects (see: s3.S3#ListObjectsV2); Listings are not cached.
+func (s *CachingStore) ListObjectsV2(input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+	return s.Store.ListObjectsV2(input)
+}
+
+// Invalidate removes the cached copy of an object (for example, one updated by another process).
+func (s *CachingStore) Invalidate(bucket, key string) error {
+	return s.invalidate(cacheKey(&bucket, &key))
+}
+
+// InvalidateNode removes the cached copy of the Node an event refers to (along with that of its related topics).
+func (s *CachingStore) InvalidateNode(bucket string, event common.NodeStoredEvent) error {
+	if err := s.Invalidate(bucket, event.ID); err != nil {
+		return err
+	}
+	return s.Invalidate(bucket, topicsf(strings.TrimPrefix(event.ID, nodef(""))))
+}
+
+// InvalidatePage removes the cached copy of the Page an event refers to.
+func (s *CachingStore) InvalidatePage(bucket string, event common.PageStoredEvent) error {
+	return s.Invalidate(bucket, event.ID)
+}
+
+func (s *CachingStore) invalidate(key string) error {
+	for _, cache := range s.caches() {
+		if err := cache.Delete(key); err != nil {
+			return fmt.Errorf("unable to invalidate cache entry %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// Returns the caches configured, in order of lookup.
+func (s *CachingStore) caches() []Cache {
+	var caches []Cache
+
+	if s.Local != nil {
+		caches = append(caches, s.Local)
+	}
+
+	if s.Shared != nil {
+		caches = append(caches, s.Shared)
+	}
+
+	return caches
+}
+
+func (obj *cachedObject) output() *s3.GetObjectOutput {
+	output := &s3.GetObjectOutput{
+		Body:          ioutil.NopCloser(bytes.NewReader(obj.Body)),
+		ContentLength: aws.Int64(int64(len(obj.Body))),
+		LastModified:  obj.LastModified,
+		Metadata:      obj.Metadata,
+	}
+
+	if obj.ContentType != "" {
+		output.ContentType = aws.String(obj.ContentType)
+	}
+
+	return output
+}
+
+func cacheKey(bucket, key *string) string {
+	return fmt.Sprintf("%s:%s", aws.StringValue(bucket), aws.StringValue(key))
+}
+
+// Generations of cache keys, incremented as the objects they refer to are written or deleted; A fill that
+// overlaps a write (and may have fetched the previous version) is discarded.  Generations are striped by hash,
+// and shared by the CachingStores of a process, so a collision costs only a discarded fill.
+var cacheGenerations [1024]uint64
+
+func cacheGeneration(key string) *uint64 {
+	hasher := newHash64()
+	hasher.Write([]byte(key))
+	return &cacheGenerations[hasher.Sum64()%uint64(len(cacheGenerations))]
+}
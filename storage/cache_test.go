@@ -0,0 +1,210 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wikimedia/phoenix/common"
+)
+
+// A Store that counts GetObject requests
+type countingStore struct {
+	*MockStore
+	gets int
+
+	// When set, called once an object has been fetched (before it is returned)
+	fetched func()
+}
+
+func (store *countingStore) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	store.gets++
+	output, err := store.MockStore.GetObject(input)
+	if store.fetched != nil {
+		store.fetched()
+	}
+	return output, err
+}
+
+func TestLRUCache(t *testing.T) {
+	t.Run("Eviction", func(t *testing.T) {
+		cache := NewLRUCache(30)
+
+		require.Nil(t, cache.Set("a", []byte("0123456789"), time.Minute))
+		require.Nil(t, cache.Set("b", []byte("0123456789"), time.Minute))
+
+		// Make "a" the most recently used
+		_, ok, _ := cache.Get("a")
+		require.True(t, ok)
+
+		require.Nil(t, cache.Set("c", []byte("0123456789"), time.Minute))
+
+		_, ok, _ = cache.Get("b")
+		assert.False(t, ok, "Least recently used entry was not evicted")
+		_, ok, _ = cache.Get("a")
+		assert.True(t, ok)
+		_, ok, _ = cache.Get("c")
+		assert.True(t, ok)
+		assert.Equal(t, 2, cache.Len())
+	})
+	t.Run("Oversized", func(t *testing.T) {
+		cache := NewLRUCache(10)
+
+		require.Nil(t, cache.Set("a", []byte("0123456789"), time.Minute))
+		_, ok, _ := cache.Get("a")
+		assert.False(t, ok)
+	})
+	t.Run("Expiration", func(t *testing.T) {
+		cache := NewLRUCache(100)
+
+		require.Nil(t, cache.Set("a", []byte("0123456789"), time.Millisecond))
+		time.Sleep(5 * time.Millisecond)
+
+		_, ok, _ := cache.Get("a")
+		assert.False(t, ok)
+		assert.Equal(t, 0, cache.Len())
+	})
+	t.Run("Delete", func(t *testing.T) {
+		cache := NewLRUCache(100)
+
+		require.Nil(t, cache.Set("a", []byte("0123456789"), time.Minute))
+		require.Nil(t, cache.Delete("a"))
+
+		_, ok, _ := cache.Get("a")
+		assert.False(t, ok)
+	})
+}
+
+func TestCachingStore(t *testing.T) {
+	backend := &countingStore{MockStore: NewMockStore()}
+	store := NewCachingStore(backend, 1<<20, time.Minute)
+	repo := Repository{Store: store, Index: NewMockIndex(), Bucket: "test"}
+
+	// A Node of no Page is read as is (without reading the Page it is a part of; see: GetNode)
+	node := testNode
+	node.Source.ID = 9
+	node.IsPartOf = nil
+
+	id, err := repo.PutNode(&node)
+	require.Nil(t, err)
+
+	t.Run("Read-through", func(t *testing.T) {
+		backend.gets = 0
+
+		for i := 0; i < 3; i++ {
+			n, err := repo.GetNode(id)
+			require.Nil(t, err)
+			assert.Equal(t, node.Unsafe, n.Unsafe)
+		}
+
+		assert.Equal(t, 1, backend.gets)
+	})
+	t.Run("Not found", func(t *testing.T) {
+		backend.gets = 0
+
+		for i := 0; i < 2; i++ {
+			_, err := repo.GetNode("/node/bogus")
+			require.NotNil(t, err)
+		}
+
+		assert.Equal(t, 2, backend.gets, "Errors should not be cached")
+	})
+	t.Run("Invalidated by writes", func(t *testing.T) {
+		node.Unsafe = "<h1>History</h1><p>Updated...</p>"
+		_, err := repo.PutNode(&node)
+		require.Nil(t, err)
+
+		n, err := repo.GetNode(id)
+		require.Nil(t, err)
+		assert.Equal(t, node.Unsafe, n.Unsafe)
+
+		require.Nil(t, repo.DeleteNode(id))
+		_, err = repo.GetNode(id)
+		require.NotNil(t, err)
+	})
+	t.Run("Overlapping write", func(t *testing.T) {
+		id, err := repo.PutNode(&node)
+		require.Nil(t, err)
+
+		updated := node
+		updated.Unsafe = "<h1>History</h1><p>Overwritten mid-fill...</p>"
+
+		// The object is overwritten after the previous version is fetched, but before it is cached
+		backend.fetched = func() {
+			backend.fetched = nil
+			_, err := repo.PutNode(&updated)
+			require.Nil(t, err)
+		}
+
+		n, err := repo.GetNode(id)
+		require.Nil(t, err)
+		assert.Equal(t, node.Unsafe, n.Unsafe)
+
+		n, err = repo.GetNode(id)
+		require.Nil(t, err)
+		assert.Equal(t, updated.Unsafe, n.Unsafe, "A stale copy was cached")
+
+		_, err = repo.PutNode(&node)
+		require.Nil(t, err)
+	})
+	t.Run("Invalidated by events", func(t *testing.T) {
+		id, err := repo.PutNode(&node)
+		require.Nil(t, err)
+
+		_, err = repo.GetNode(id)
+		require.Nil(t, err)
+
+		// Updated by another process
+		other := Repository{Store: backend, Index: NewMockIndex(), Bucket: "test"}
+		updated := node
+		updated.Unsafe = "<h1>History</h1><p>Updated elsewhere...</p>"
+		_, err = other.PutNode(&updated)
+		require.Nil(t, err)
+
+		n, err := repo.GetNode(id)
+		require.Nil(t, err)
+		assert.Equal(t, node.Unsafe, n.Unsafe, "Expected a cached copy")
+
+		require.Nil(t, store.InvalidateNode("test", common.NodeStoredEvent{ID: id}))
+
+		n, err = repo.GetNode(id)
+		require.Nil(t, err)
+		assert.Equal(t, updated.Unsafe, n.Unsafe)
+	})
+}
+
+func TestCachingStoreShared(t *testing.T) {
+	backend := &countingStore{MockStore: NewMockStore()}
+	shared := NewMemoryCache()
+
+	// Two processes, sharing a cache
+	a := &CachingStore{Store: backend, Local: NewLRUCache(1 << 20), Shared: shared, TTL: time.Minute}
+	b := &CachingStore{Store: backend, Local: NewLRUCache(1 << 20), Shared: shared, TTL: time.Minute}
+
+	repoA := Repository{Store: a, Index: NewMockIndex(), Bucket: "test"}
+	repoB := Repository{Store: b, Index: NewMockIndex(), Bucket: "test"}
+
+	page := testPage
+	page.Source.ID = 10
+
+	id, err := repoA.PutPage(&page)
+	require.Nil(t, err)
+
+	backend.gets = 0
+
+	_, err = repoA.GetPage(id)
+	require.Nil(t, err)
+	_, err = repoB.GetPage(id)
+	require.Nil(t, err)
+
+	assert.Equal(t, 1, backend.gets, "Expected the second read to be served from the shared cache")
+
+	// An event invalidates the shared entry (and the local one of the process handling it)
+	require.Nil(t, b.InvalidatePage("test", common.PageStoredEvent{ID: id}))
+
+	_, ok, err := shared.Get(cacheKey(&repoA.Bucket, &id))
+	require.Nil(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,409 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/wikimedia/phoenix/common"
+)
+
+// Kinds of Problem reported by Check
+const (
+	// A Page.HasPart entry refers to a Node that does not exist
+	ProblemMissingNode = "missing-node"
+
+	// A Node's IsPartOf does not refer back to the Page that has it
+	ProblemBadParent = "bad-parent"
+
+	// A Page.About entry refers to a linked-data object that does not exist
+	ProblemMissingAbout = "missing-about"
+
+	// A Node that no Page has as a part
+	ProblemOrphanNode = "orphan-node"
+
+	// A Page (or Node) name that is not indexed, or that is indexed to some other ID
+	ProblemPageIndex = "page-index"
+	ProblemNodeIndex = "node-index"
+
+	// An index entry that refers to an object which does not exist, or which goes by some other name
+	ProblemStaleIndex = "stale-index"
+)
+
+// Problem is an inconsistency found by Check
+type Problem struct {
+	// One of the Problem* constants
+	Kind string `json:"kind"`
+
+	// ID of the object the problem was found in (for index entries, the ID the entry refers to)
+	ID string `json:"id"`
+
+	// The reference (an ID, or index entry) at fault, if any
+	Ref string `json:"ref,omitempty"`
+
+	Message string `json:"message"`
+
+	// True if the problem was repaired
+	Repaired bool `json:"repaired"`
+}
+
+// CheckReport is the outcome of Check
+type CheckReport struct {
+	Pages        int       `json:"pages"`
+	Nodes        int       `json:"nodes"`
+	IndexEntries int       `json:"indexEntries"`
+	Problems     []Problem `json:"problems"`
+}
+
+// CheckOptions configure a Check
+type CheckOptions struct {
+	// If non-empty, only the objects (and index entries) of this wiki are checked
+	Authority string
+
+	// Repair the problems found
+	Repair bool
+}
+
+// Check walks the repository, reporting dangling references (Page.HasPart entries, Page.About entries, and
+// Node.IsPartOf back-references), orphaned Nodes, and drift between the repository and its Index.  Index
+// entries are only checked for objects that do not exist if the Index implements IndexScanner.
+//
+// When options.Repair is set, problems are repaired as they are found: Dangling references are dropped,
+// back-references are rewritten, orphaned Nodes are deleted (their revision history is retained), and the
+// Index is updated to match the repository.  A Page whose parts are all missing is left as-is; Pages must
+// have at least one part.
+//
+// NOTE: Updates applied during a Check can appear as problems (a Node written ahead of its Page, for example,
+// looks orphaned); Do not repair a repository while it is being updated.
+func (r *Repository) Check(options CheckOptions) (*CheckReport, error) {
+	var c = &checker{
+		repo:      r,
+		options:   options,
+		report:    &CheckReport{Problems: make([]Problem, 0)},
+		pageNames: make(map[string]string),
+		parents:   make(map[string]string),
+	}
+	var err error
+
+	if err = c.checkPages(); err != nil {
+		return nil, err
+	}
+
+	if err = c.checkNodes(); err != nil {
+		return nil, err
+	}
+
+	if scanner, ok := r.Index.(IndexScanner); ok {
+		if err = c.checkIndex(scanner); err != nil {
+			return nil, err
+		}
+	}
+
+	return c.report, nil
+}
+
+// State of a Check in progress
+type checker struct {
+	repo    *Repository
+	options CheckOptions
+	report  *CheckReport
+
+	// Names of Pages, by ID
+	pageNames map[string]string
+
+	// Names of the Pages that have Nodes as parts, by Node ID
+	parents map[string]string
+}
+
+// Records a problem, returning its index in the report.
+func (c *checker) problem(kind, id, ref, format string, a ...interface{}) int {
+	c.report.Problems = append(c.report.Problems, Problem{Kind: kind, ID: id, Ref: ref, Message: fmt.Sprintf(format, a...)})
+	return len(c.report.Problems) - 1
+}
+
+// Repairs are recorded against the problems they fix, once they succeed.
+func (c *checker) repaired(problems ...int) {
+	for _, i := range problems {
+		c.report.Problems[i].Repaired = true
+	}
+}
+
+func (c *checker) checkPages() error {
+	var cursor string
+
+	for {
+		var pages []*common.Page
+		var err error
+
+		if pages, cursor, err = c.repo.ListPages(c.options.Authority, cursor, 0); err != nil {
+			return err
+		}
+
+		for _, page := range pages {
+			if err = c.checkPage(page); err != nil {
+				return fmt.Errorf("error checking %s: %w", page.ID, err)
+			}
+		}
+
+		if cursor == "" {
+			return nil
+		}
+	}
+}
+
+func (c *checker) checkPage(page *common.Page) error {
+	var about = make(map[string]string)
+	var dangling []int
+	var existing []*common.Node
+	var indexing []int
+	var nodes []*common.Node
+	var parts []string
+	var err error
+
+	c.report.Pages++
+	c.pageNames[page.ID] = page.Name
+
+	// Nodes are checked as stored, rather than as resolved through the Page they claim to be a part of (see: GetNode)
+	if nodes, err = c.repo.getNodes(page.HasPart, c.repo.getNode); err != nil {
+		return err
+	}
+
+	for i, node := range nodes {
+		c.parents[page.HasPart[i]] = page.Name
+
+		if node == nil {
+			dangling = append(dangling, c.problem(ProblemMissingNode, page.ID, page.HasPart[i], "part %s does not exist", page.HasPart[i]))
+			continue
+		}
+
+		parts = append(parts, node.ID)
+		existing = append(existing, node)
+
+		if !contains(node.IsPartOf, page.ID) {
+			problem := c.problem(ProblemBadParent, node.ID, page.ID, "isPartOf %v does not include %s", node.IsPartOf, page.ID)
+			if c.options.Repair {
+				if err = c.reparent(node, page); err != nil {
+					return err
+				}
+				c.repaired(problem)
+			}
+		}
+	}
+
+	for vocabulary, id := range page.About {
+		if _, err = c.repo.GetAbout(id); err != nil {
+			var nerr *ErrNotFound
+			if !errors.As(err, &nerr) {
+				return err
+			}
+			dangling = append(dangling, c.problem(ProblemMissingAbout, page.ID, id, "linked data (%s) %s does not exist", vocabulary, id))
+			continue
+		}
+		about[vocabulary] = id
+	}
+
+	// Index drift
+	if id, err := c.repo.Index.PageIDForName(page.Source.Authority, page.Name); err != nil || id != page.ID {
+		if ok, err := isDrift(err); !ok {
+			return err
+		}
+		indexing = append(indexing, c.problem(ProblemPageIndex, page.ID, page.Name, "page name %q resolves to %q", page.Name, id))
+	}
+
+	for _, node := range existing {
+		if id, err := c.repo.Index.NodeIDForName(node.Source.Authority, page.Name, node.Name); err != nil || id != node.ID {
+			if ok, err := isDrift(err); !ok {
+				return err
+			}
+			indexing = append(indexing, c.problem(ProblemNodeIndex, node.ID, node.Name, "node name %q (of %q) resolves to %q", node.Name, page.Name, id))
+		}
+	}
+
+	if !c.options.Repair {
+		return nil
+	}
+
+	if len(dangling) > 0 && len(parts) > 0 {
+		if err = c.rewrite(page, parts, about); err != nil {
+			return err
+		}
+
+		c.repaired(dangling...)
+	}
+
+	if len(indexing) > 0 {
+		if err = c.repo.Index.Apply(&Update{Page: *page, Nodes: nodeValues(existing)}); err != nil {
+			return err
+		}
+
+		c.repaired(indexing...)
+	}
+
+	return nil
+}
+
+func (c *checker) checkNodes() error {
+	var cursor string
+
+	for {
+		var nodes []*common.Node
+		var err error
+
+		if nodes, cursor, err = c.repo.ListNodes(c.options.Authority, cursor, 0); err != nil {
+			return err
+		}
+
+		for _, node := range nodes {
+			c.report.Nodes++
+
+			if _, ok := c.parents[node.ID]; ok {
+				continue
+			}
+
+			problem := c.problem(ProblemOrphanNode, node.ID, "", "not a part of any page")
+
+			if c.options.Repair {
+				if err = c.repo.deleteNode(node.ID); err != nil {
+					return fmt.Errorf("error deleting %s: %w", node.ID, err)
+				}
+				c.repaired(problem)
+			}
+		}
+
+		if cursor == "" {
+			return nil
+		}
+	}
+}
+
+func (c *checker) checkIndex(scanner IndexScanner) error {
+	var err error
+
+	err = scanner.ScanPages(func(authority, name, id string) error {
+		if c.options.Authority != "" && authority != c.options.Authority {
+			return nil
+		}
+
+		c.report.IndexEntries++
+
+		if actual, ok := c.pageNames[id]; ok && actual == name {
+			return nil
+		}
+
+		problem := c.problem(ProblemStaleIndex, id, fmt.Sprintf("%s/%s", authority, name), "page name %q does not resolve to a page of that name", name)
+
+		if c.options.Repair {
+			if err := c.repo.Index.RemovePage(authority, name); err != nil {
+				return err
+			}
+			c.repaired(problem)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("error scanning page index: %w", err)
+	}
+
+	err = scanner.ScanNodes(func(authority, pageName, name, id string) error {
+		var node *common.Node
+		var err error
+
+		if c.options.Authority != "" && authority != c.options.Authority {
+			return nil
+		}
+
+		c.report.IndexEntries++
+
+		if node, err = c.repo.getNode(id); err != nil {
+			var nerr *ErrNotFound
+			if !errors.As(err, &nerr) {
+				return err
+			}
+		}
+
+		// Node names are indexed lower-cased
+		if actual, ok := c.parents[id]; ok && actual == pageName && node != nil && strings.ToLower(node.Name) == name {
+			return nil
+		}
+
+		problem := c.problem(ProblemStaleIndex, id, fmt.Sprintf("%s/%s/%s", authority, pageName, name), "node name %q (of %q) does not resolve to a node of that name", name, pageName)
+
+		if c.options.Repair {
+			if err = c.repo.Index.RemoveNode(authority, pageName, name); err != nil {
+				return err
+			}
+			c.repaired(problem)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("error scanning node index: %w", err)
+	}
+
+	return nil
+}
+
+// Drops the dangling references of page, rewriting its current version; Its revision history (including the
+// revision page is a copy of) is left as is.
+func (c *checker) rewrite(page *common.Page, parts []string, about map[string]string) error {
+	var data []byte
+	var err error
+
+	page.HasPart = parts
+	page.About = about
+
+	if data, err = encodeJSON(page); err != nil {
+		return err
+	}
+
+	return c.repo.put(page.ID, data, pageMetadata())
+}
+
+// Makes node a part of page (Nodes are a part of exactly one Page), rewriting the current version of the node.
+func (c *checker) reparent(node *common.Node, page *common.Page) error {
+	var data []byte
+	var err error
+
+	node.IsPartOf = []string{page.ID}
+
+	if data, err = encodeJSON(node); err != nil {
+		return err
+	}
+
+	return c.repo.put(node.ID, data, nodeMetadata())
+}
+
+// Returns true if err is nil, or an ErrNotFound (an index entry that is merely missing); Otherwise, err is
+// returned.
+func isDrift(err error) (bool, error) {
+	var nerr *ErrNotFound
+
+	if err == nil || errors.As(err, &nerr) {
+		return true, nil
+	}
+
+	return false, err
+}
+
+func nodeValues(nodes []*common.Node) []common.Node {
+	var values = make([]common.Node, len(nodes))
+
+	for i, node := range nodes {
+		values[i] = *node
+	}
+
+	return values
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
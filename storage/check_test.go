@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wikimedia/phoenix/common"
+)
+
+func TestRepositoryCheck(t *testing.T) {
+	index := NewMockIndex()
+	repo := Repository{Store: NewMockStore(), Index: index, Bucket: "test"}
+
+	history := testNode
+	geography := testNode
+	geography.Name = "Geography"
+
+	update := &Update{
+		Page:   testPage,
+		Nodes:  []common.Node{history, geography},
+		Abouts: map[string]common.Thing{"//schema.org": testAbout},
+	}
+
+	_, err := repo.Apply(update)
+	require.Nil(t, err)
+
+	page, err := repo.GetPage(pagef(makePageID(&testPage)))
+	require.Nil(t, err)
+	require.Len(t, page.HasPart, 2)
+
+	// Returns the kinds of problem reported, and the number of each
+	kinds := func(report *CheckReport) map[string]int {
+		var res = make(map[string]int)
+		for _, problem := range report.Problems {
+			res[problem.Kind]++
+		}
+		return res
+	}
+
+	t.Run("Consistent", func(t *testing.T) {
+		report, err := repo.Check(CheckOptions{})
+		require.Nil(t, err)
+		assert.Equal(t, 1, report.Pages)
+		assert.Equal(t, 2, report.Nodes)
+		assert.Equal(t, 3, report.IndexEntries)
+		assert.Empty(t, report.Problems)
+	})
+
+	// Introduce one of every kind of problem
+	historyID, geographyID := nodef(makeNodeID(&history)), nodef(makeNodeID(&geography))
+
+	require.Nil(t, repo.delete([]string{geographyID, page.About["//schema.org"]}))
+
+	node, err := repo.GetNode(historyID)
+	require.Nil(t, err)
+	node.IsPartOf = []string{pagef("bogus")}
+	data, err := encodeJSON(node)
+	require.Nil(t, err)
+	require.Nil(t, repo.put(historyID, data, nodeMetadata()))
+
+	orphan := testNode
+	orphan.Source.ID = 2
+	_, err = repo.PutNode(&orphan)
+	require.Nil(t, err)
+
+	// A node that claims the page, but that the page does not refer to (one that was not garbage-collected)
+	stray := testNode
+	stray.Name = "Culture"
+	stray.IsPartOf = []string{page.ID}
+	_, err = repo.PutNode(&stray)
+	require.Nil(t, err)
+
+	require.Nil(t, index.RemovePage(page.Source.Authority, page.Name))
+
+	stale := testPage
+	stale.ID = pagef("missing")
+	stale.Name = "Elsewhere"
+	require.Nil(t, index.Apply(&Update{Page: stale}))
+
+	expected := map[string]int{
+		ProblemMissingNode:  1,
+		ProblemBadParent:    1,
+		ProblemMissingAbout: 1,
+		ProblemOrphanNode:   2,
+		ProblemPageIndex:    1,
+		ProblemStaleIndex:   2, // Elsewhere, and Geography
+	}
+
+	t.Run("Report", func(t *testing.T) {
+		report, err := repo.Check(CheckOptions{})
+		require.Nil(t, err)
+		assert.Equal(t, expected, kinds(report))
+
+		for _, problem := range report.Problems {
+			assert.False(t, problem.Repaired)
+		}
+
+		// Nothing was modified
+		again, err := repo.Check(CheckOptions{})
+		require.Nil(t, err)
+		assert.Equal(t, report, again)
+	})
+
+	t.Run("Authority", func(t *testing.T) {
+		report, err := repo.Check(CheckOptions{Authority: "other.wikipedia.org"})
+		require.Nil(t, err)
+		assert.Equal(t, 0, report.Pages)
+		assert.Equal(t, 0, report.Nodes)
+		assert.Equal(t, 0, report.IndexEntries)
+		assert.Empty(t, report.Problems)
+	})
+
+	t.Run("Repair", func(t *testing.T) {
+		revisions, err := repo.ListRevisions(page.ID)
+		require.Nil(t, err)
+
+		report, err := repo.Check(CheckOptions{Repair: true})
+		require.Nil(t, err)
+		assert.Equal(t, expected, kinds(report))
+
+		for _, problem := range report.Problems {
+			assert.True(t, problem.Repaired, "Not repaired: %+v", problem)
+		}
+
+		report, err = repo.Check(CheckOptions{})
+		require.Nil(t, err)
+		assert.Empty(t, report.Problems)
+		assert.Equal(t, 1, report.Pages)
+		assert.Equal(t, 1, report.Nodes)
+		assert.Equal(t, 2, report.IndexEntries)
+
+		page, err := repo.GetPageByName(testPage.Source.Authority, testPage.Name)
+		require.Nil(t, err)
+		assert.Equal(t, []string{historyID}, page.HasPart)
+		assert.Empty(t, page.About)
+
+		// Only the current version is rewritten; The revision history is left as is
+		after, err := repo.ListRevisions(page.ID)
+		require.Nil(t, err)
+		assert.Equal(t, revisions, after)
+
+		historical, err := repo.GetPageAtRevision(page.ID, page.Source.Revision)
+		require.Nil(t, err)
+		assert.Len(t, historical.HasPart, 2)
+		assert.Len(t, historical.About, 1)
+
+		node, err := repo.GetNode(historyID)
+		require.Nil(t, err)
+		assert.Equal(t, []string{page.ID}, node.IsPartOf)
+
+		var notFound *ErrNotFound
+		_, err = repo.GetNode(nodef(makeNodeID(&orphan)))
+		assert.True(t, errors.As(err, &notFound))
+		_, err = repo.getNode(nodef(makeNodeID(&stray)))
+		assert.True(t, errors.As(err, &notFound))
+	})
+}
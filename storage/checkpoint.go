@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// ReadCheckpoint decodes the progress recorded to a checkpoint file (see: WriteCheckpoint) into progress (a
+// *MigrateProgress, or *ReindexProgress).  If path is empty, or the file does not exist, progress is left as is.
+func ReadCheckpoint(path string, progress interface{}) error {
+	var b []byte
+	var err error
+
+	if path == "" {
+		return nil
+	}
+
+	if b, err = ioutil.ReadFile(path); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	if err = json.Unmarshal(b, progress); err != nil {
+		return fmt.Errorf("unable to deserialize checkpoint %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// WriteCheckpoint records progress (a MigrateProgress, or ReindexProgress) to a checkpoint file, replacing it
+// atomically.  If path is empty, nothing is recorded.
+func WriteCheckpoint(path string, progress interface{}) error {
+	var b []byte
+	var err error
+
+	if path == "" {
+		return nil
+	}
+
+	if b, err = json.Marshal(progress); err != nil {
+		return fmt.Errorf("unable to serialize checkpoint: %w", err)
+	}
+
+	return writeFileAtomic(path, b)
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phoenix-checkpoint")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "checkpoint.json")
+
+	t.Run("Missing", func(t *testing.T) {
+		progress := ReindexProgress{Pages: 1}
+		require.NoError(t, ReadCheckpoint(path, &progress))
+		assert.Equal(t, ReindexProgress{Pages: 1}, progress)
+	})
+
+	t.Run("Unset", func(t *testing.T) {
+		var progress ReindexProgress
+		require.NoError(t, WriteCheckpoint("", ReindexProgress{Pages: 1}))
+		require.NoError(t, ReadCheckpoint("", &progress))
+		assert.Equal(t, ReindexProgress{}, progress)
+	})
+
+	t.Run("Round trip", func(t *testing.T) {
+		var progress MigrateProgress
+		require.NoError(t, WriteCheckpoint(path, MigrateProgress{Cursor: "/page/1", Objects: 10, Migrated: 2}))
+		require.NoError(t, WriteCheckpoint(path, MigrateProgress{Cursor: "/page/2", Objects: 20, Migrated: 3}))
+		require.NoError(t, ReadCheckpoint(path, &progress))
+		assert.Equal(t, MigrateProgress{Cursor: "/page/2", Objects: 20, Migrated: 3}, progress)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		var progress MigrateProgress
+		require.NoError(t, ioutil.WriteFile(path, []byte("{"), 0644))
+		assert.Error(t, ReadCheckpoint(path, &progress))
+	})
+}
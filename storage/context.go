@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// ContextStore is a Store whose requests can be bound to a context (for example, CachingStore).  Stores that
+// are not, but that implement the context variants of the s3.S3 methods (as s3.S3 does), are bound using those.
+type ContextStore interface {
+	Store
+
+	// WithContext returns a copy of the Store whose requests are made with ctx.
+	WithContext(ctx context.Context) Store
+}
+
+// ContextIndex is an Index whose requests can be bound to a context.
+type ContextIndex interface {
+	Index
+
+	// WithContext returns a copy of the Index whose requests are made with ctx.
+	WithContext(ctx context.Context) Index
+}
+
+// ContextTopicSearch is a TopicSearch whose requests can be bound to a context.
+type ContextTopicSearch interface {
+	TopicSearch
+
+	// WithContext returns a copy of the TopicSearch whose requests are made with ctx.
+	WithContext(ctx context.Context) TopicSearch
+}
+
+// WithContext returns a copy of the Repository whose Store, Index, and TopicSearch requests are made with ctx, so
+// that they are abandoned once ctx is cancelled (or its deadline passes); Such requests fail with (an error
+// wrapping) ctx.Err().  Requests of an Index or TopicSearch that cannot be bound to a context are made as usual,
+// while those of a Store are refused once ctx is done.  Hooks and Outbox are shared with the Repository.
+func (r *Repository) WithContext(ctx context.Context) *Repository {
+	var repo = *r
+
+	repo.Store = storeWithContext(r.Store, ctx)
+
+	if index, ok := r.Index.(ContextIndex); ok {
+		repo.Index = index.WithContext(ctx)
+	}
+
+	if search, ok := r.TopicSearch.(ContextTopicSearch); ok {
+		repo.TopicSearch = search.WithContext(ctx)
+	}
+
+	return &repo
+}
+
+// Returns store, bound to ctx
+func storeWithContext(store Store, ctx context.Context) Store {
+	switch s := store.(type) {
+	case ContextStore:
+		return s.WithContext(ctx)
+	case *contextStore:
+		return &contextStore{Store: s.Store, ctx: ctx}
+	default:
+		return &contextStore{Store: store, ctx: ctx}
+	}
+}
+
+// The context variants of the s3.S3 methods (see: s3iface.S3API)
+type awsContextStore interface {
+	PutObjectWithContext(aws.Context, *s3.PutObjectInput, ...request.Option) (*s3.PutObjectOutput, error)
+	GetObjectWithContext(aws.Context, *s3.GetObjectInput, ...request.Option) (*s3.GetObjectOutput, error)
+	DeleteObjectsWithContext(aws.Context, *s3.DeleteObjectsInput, ...request.Option) (*s3.DeleteObjectsOutput, error)
+	ListObjectsV2WithContext(aws.Context, *s3.ListObjectsV2Input, ...request.Option) (*s3.ListObjectsV2Output, error)
+}
+
+// A Store bound to a context
+type contextStore struct {
+	Store
+	ctx context.Context
+}
+
+func (s *contextStore) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	if store, ok := s.Store.(awsContextStore); ok {
+		return store.PutObjectWithContext(s.ctx, input)
+	}
+
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return s.Store.PutObject(input)
+}
+
+func (s *contextStore) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	if store, ok := s.Store.(awsContextStore); ok {
+		return store.GetObjectWithContext(s.ctx, input)
+	}
+
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return s.Store.GetObject(input)
+}
+
+func (s *contextStore) DeleteObjects(input *s3.DeleteObjectsInput) (*s3.DeleteObjectsOutput, error) {
+	if store, ok := s.Store.(awsContextStore); ok {
+		return store.DeleteObjectsWithContext(s.ctx, input)
+	}
+
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return s.Store.DeleteObjects(input)
+}
+
+func (s *contextStore) ListObjectsV2(input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+	if store, ok := s.Store.(awsContextStore); ok {
+		return store.ListObjectsV2WithContext(s.ctx, input)
+	}
+
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return s.Store.ListObjectsV2(input)
+}
+
+// Returns ctx, or if it is nil, the background context; Components not bound to a context (see: WithContext)
+// make their requests with the latter.
+func contextOrBackground(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return ctx
+}
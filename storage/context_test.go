@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wikimedia/phoenix/common"
+)
+
+// A Store implementing the context variant of GetObject (as s3.S3 does), recording the contexts it is passed
+type awsStore struct {
+	*MockStore
+	contexts []aws.Context
+}
+
+func (s *awsStore) PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	s.contexts = append(s.contexts, ctx)
+	return s.MockStore.PutObject(input)
+}
+
+func (s *awsStore) GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error) {
+	s.contexts = append(s.contexts, ctx)
+	return s.MockStore.GetObject(input)
+}
+
+func (s *awsStore) DeleteObjectsWithContext(ctx aws.Context, input *s3.DeleteObjectsInput, opts ...request.Option) (*s3.DeleteObjectsOutput, error) {
+	s.contexts = append(s.contexts, ctx)
+	return s.MockStore.DeleteObjects(input)
+}
+
+func (s *awsStore) ListObjectsV2WithContext(ctx aws.Context, input *s3.ListObjectsV2Input, opts ...request.Option) (*s3.ListObjectsV2Output, error) {
+	s.contexts = append(s.contexts, ctx)
+	return s.MockStore.ListObjectsV2(input)
+}
+
+type contextKey string
+
+func TestRepositoryWithContext(t *testing.T) {
+	page := testPage
+	page.Name = "Lockhart"
+
+	t.Run("Cancelled", func(t *testing.T) {
+		repo := &Repository{Store: NewMockStore(), Index: NewMockIndex(), Bucket: "test"}
+
+		result, err := repo.Apply(&Update{Page: page, Nodes: []common.Node{testNode}})
+		require.Nil(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		bound := repo.WithContext(ctx)
+
+		_, err = bound.GetPage(result.PageID)
+		require.Nil(t, err)
+
+		cancel()
+
+		_, err = bound.GetPage(result.PageID)
+		require.NotNil(t, err)
+		assert.True(t, errors.Is(err, context.Canceled), "Expected an error wrapping context.Canceled")
+
+		_, err = bound.Apply(&Update{Page: page, Nodes: []common.Node{testNode}})
+		assert.True(t, errors.Is(err, context.Canceled), "Expected an error wrapping context.Canceled")
+
+		// The Repository itself is unaffected
+		_, err = repo.GetPage(result.PageID)
+		require.Nil(t, err)
+	})
+
+	t.Run("Deadline", func(t *testing.T) {
+		repo := &Repository{Store: NewMockStore(), Index: NewMockIndex(), Bucket: "test"}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+		defer cancel()
+		<-ctx.Done()
+
+		_, err := repo.WithContext(ctx).GetPage(pagef("a0a0a0a0a0a0a"))
+		assert.True(t, IsRetryable(err), "Expected a retryable error, got: %v", err)
+	})
+
+	t.Run("Context variants", func(t *testing.T) {
+		store := &awsStore{MockStore: NewMockStore()}
+		repo := &Repository{Store: NewCachingStore(store, 1<<20, time.Minute), Index: NewMockIndex(), Bucket: "test"}
+
+		ctx := context.WithValue(context.Background(), contextKey("test"), "context variants")
+		bound := repo.WithContext(ctx)
+
+		// Rebinding replaces the context
+		_, err := bound.WithContext(ctx).Apply(&Update{Page: page, Nodes: []common.Node{testNode}})
+		require.Nil(t, err)
+
+		require.NotEmpty(t, store.contexts)
+		for _, c := range store.contexts {
+			assert.Equal(t, ctx, c)
+		}
+	})
+
+	t.Run("Index", func(t *testing.T) {
+		repo := &Repository{Store: NewMockStore(), Index: &DynamoDBIndex{}, TopicSearch: ElasticTopicSearch{}, Bucket: "test"}
+		ctx := context.Background()
+
+		bound := repo.WithContext(ctx)
+		assert.Equal(t, ctx, bound.Index.(*DynamoDBIndex).ctx)
+		assert.Equal(t, ctx, bound.TopicSearch.(ElasticTopicSearch).ctx)
+		assert.Nil(t, repo.Index.(*DynamoDBIndex).ctx)
+	})
+}
@@ -0,0 +1,545 @@
+package storage
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wikimedia/phoenix/common"
+)
+
+// DumpVersion is the version of the dump format written (see: DUMP.md).  It is incremented whenever a change
+// to the format would prevent an older reader from loading a dump correctly.
+const DumpVersion = 1
+
+// Types of DumpRecord
+const (
+	DumpHeaderRecord = "header"
+	DumpPageRecord   = "page"
+	DumpNodeRecord   = "node"
+	DumpAboutRecord  = "about"
+	DumpTopicsRecord = "topics"
+)
+
+// DumpHeader is the first record of every dump
+type DumpHeader struct {
+	Version       int        `json:"version"`
+	Created       time.Time  `json:"created"`
+	Authority     string     `json:"authority,omitempty"`
+	ModifiedSince *time.Time `json:"modifiedSince,omitempty"`
+}
+
+// DumpRecord is a single object of a dump.  Exactly one of the Header, Page, Node, About, or Topics attributes
+// is set, according to Type.  ID is the key of the object in the repository (for Topics, that of the Node they
+// relate to).
+type DumpRecord struct {
+	Type   string                `json:"type"`
+	ID     string                `json:"id,omitempty"`
+	Header *DumpHeader           `json:"header,omitempty"`
+	Page   *common.Page          `json:"page,omitempty"`
+	Node   *common.Node          `json:"node,omitempty"`
+	About  *common.Thing         `json:"about,omitempty"`
+	Topics []common.RelatedTopic `json:"topics,omitempty"`
+}
+
+// DumpWriter is implemented by writers of the dump formats
+type DumpWriter interface {
+	Write(record *DumpRecord) error
+	Close() error
+}
+
+// JSONDumpWriter writes a dump as newline-delimited JSON, one record per line.
+type JSONDumpWriter struct {
+	encoder *json.Encoder
+}
+
+// NewJSONDumpWriter returns a JSONDumpWriter that writes to w
+func NewJSONDumpWriter(w io.Writer) *JSONDumpWriter {
+	var encoder = json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return &JSONDumpWriter{encoder: encoder}
+}
+
+// Write writes a record
+func (w *JSONDumpWriter) Write(record *DumpRecord) error {
+	return w.encoder.Encode(record)
+}
+
+// Close is a no-op; Closing the underlying io.Writer is the responsibility of the caller.
+func (w *JSONDumpWriter) Close() error {
+	return nil
+}
+
+// TarDumpWriter writes a dump as a tar archive, one file per record.  Files are named for the record type
+// and ID (for example, page/7d3a1e52b5a8e0f3.json), and contain the JSON-encoded record.
+type TarDumpWriter struct {
+	writer  *tar.Writer
+	created time.Time
+}
+
+// NewTarDumpWriter returns a TarDumpWriter that writes to w
+func NewTarDumpWriter(w io.Writer) *TarDumpWriter {
+	return &TarDumpWriter{writer: tar.NewWriter(w)}
+}
+
+// Write writes a record
+func (w *TarDumpWriter) Write(record *DumpRecord) error {
+	var b []byte
+	var err error
+	var name string
+
+	if b, err = json.Marshal(record); err != nil {
+		return err
+	}
+
+	if record.Type == DumpHeaderRecord {
+		name = "header.json"
+		w.created = record.Header.Created
+	} else {
+		name = fmt.Sprintf("%s/%s.json", record.Type, record.ID[strings.LastIndex(record.ID, "/")+1:])
+	}
+
+	// Files are timestamped with the creation of the dump (rather than the time written), so that archives of
+	// the same contents differ only by the header.
+	header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(b)), ModTime: w.created}
+
+	if err = w.writer.WriteHeader(header); err != nil {
+		return err
+	}
+
+	_, err = w.writer.Write(b)
+	return err
+}
+
+// Close writes the end of the archive; Closing the underlying io.Writer is the responsibility of the caller.
+func (w *TarDumpWriter) Close() error {
+	return w.writer.Close()
+}
+
+// DumpReader is implemented by readers of the dump formats.  Read returns io.EOF once there are no more records.
+type DumpReader interface {
+	Read() (*DumpRecord, error)
+}
+
+// NewDumpReader returns a DumpReader for r, detecting the format (and compression) of the dump.
+func NewDumpReader(r io.Reader) (DumpReader, error) {
+	var buffered = bufio.NewReader(r)
+	var magic []byte
+	var err error
+
+	// gzip
+	if magic, err = buffered.Peek(2); err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		var decompressor *gzip.Reader
+
+		if decompressor, err = gzip.NewReader(buffered); err != nil {
+			return nil, fmt.Errorf("unable to read dump: %w", err)
+		}
+
+		return NewDumpReader(decompressor)
+	}
+
+	// tar (the "ustar" magic of the first header)
+	if magic, err = buffered.Peek(262); err == nil && bytes.Equal(magic[257:], []byte("ustar")) {
+		return NewTarDumpReader(buffered), nil
+	}
+
+	return NewJSONDumpReader(buffered), nil
+}
+
+// JSONDumpReader reads a dump of newline-delimited JSON
+type JSONDumpReader struct {
+	decoder *json.Decoder
+}
+
+// NewJSONDumpReader returns a JSONDumpReader that reads from r
+func NewJSONDumpReader(r io.Reader) *JSONDumpReader {
+	return &JSONDumpReader{decoder: json.NewDecoder(r)}
+}
+
+// Read returns the next record
+func (r *JSONDumpReader) Read() (*DumpRecord, error) {
+	var record DumpRecord
+
+	if err := r.decoder.Decode(&record); err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, fmt.Errorf("unable to read dump: %w", err)
+	}
+
+	return &record, nil
+}
+
+// TarDumpReader reads a dump from a tar archive
+type TarDumpReader struct {
+	reader *tar.Reader
+}
+
+// NewTarDumpReader returns a TarDumpReader that reads from r
+func NewTarDumpReader(r io.Reader) *TarDumpReader {
+	return &TarDumpReader{reader: tar.NewReader(r)}
+}
+
+// Read returns the next record
+func (r *TarDumpReader) Read() (*DumpRecord, error) {
+	var header *tar.Header
+	var record DumpRecord
+	var err error
+
+	for {
+		if header, err = r.reader.Next(); err != nil {
+			if err == io.EOF {
+				return nil, err
+			}
+			return nil, fmt.Errorf("unable to read dump: %w", err)
+		}
+
+		// Directories, or anything else added to the archive by hand
+		if header.Typeflag == tar.TypeReg && strings.HasSuffix(header.Name, ".json") {
+			break
+		}
+	}
+
+	if err = json.NewDecoder(r.reader).Decode(&record); err != nil {
+		return nil, fmt.Errorf("unable to read dump (%s): %w", header.Name, err)
+	}
+
+	return &record, nil
+}
+
+// ExportOptions configure an Export
+type ExportOptions struct {
+	// If non-empty, only the Pages of this wiki are exported
+	Authority string
+
+	// If non-zero, only Pages modified at, or after this time are exported
+	ModifiedSince time.Time
+}
+
+// DumpStats reports the number of objects exported (or imported)
+type DumpStats struct {
+	Pages  int `json:"pages"`
+	Nodes  int `json:"nodes"`
+	Abouts int `json:"abouts"`
+	Topics int `json:"topics"`
+
+	// Pages not imported because the stored version is up to date (or newer)
+	Skipped int `json:"skipped,omitempty"`
+}
+
+// Export writes the contents of the repository to w, beginning with a header.  Pages are written in order of
+// ID, each followed by its Nodes (in document order, each followed by its related topics, if any), and then its
+// linked-data objects (in order of vocabulary); Dumps of the same contents are identical, save for the header.
+// Parts of a Page (and linked-data objects) that do not exist are skipped.
+//
+// The caller is responsible for closing w.
+func (r *Repository) Export(w DumpWriter, options ExportOptions) (*DumpStats, error) {
+	var cursor string
+	var header = &DumpHeader{Version: DumpVersion, Created: time.Now().UTC(), Authority: options.Authority}
+	var stats = &DumpStats{}
+	var err error
+
+	if !options.ModifiedSince.IsZero() {
+		header.ModifiedSince = &options.ModifiedSince
+	}
+
+	if err = w.Write(&DumpRecord{Type: DumpHeaderRecord, Header: header}); err != nil {
+		return stats, fmt.Errorf("error writing dump: %w", err)
+	}
+
+	for {
+		var pages []*common.Page
+
+		if pages, cursor, err = r.ListPages(options.Authority, cursor, 0); err != nil {
+			return stats, err
+		}
+
+		for _, page := range pages {
+			if page.DateModified.Before(options.ModifiedSince) {
+				continue
+			}
+
+			if err = r.exportPage(w, page, stats); err != nil {
+				return stats, fmt.Errorf("error exporting %s: %w", page.ID, err)
+			}
+		}
+
+		if cursor == "" {
+			return stats, nil
+		}
+	}
+}
+
+func (r *Repository) exportPage(w DumpWriter, page *common.Page, stats *DumpStats) error {
+	var nodes []*common.Node
+	var vocabularies []string
+	var err error
+
+	if err = w.Write(&DumpRecord{Type: DumpPageRecord, ID: page.ID, Page: page}); err != nil {
+		return err
+	}
+
+	stats.Pages++
+
+	if nodes, err = r.GetNodes(page.HasPart); err != nil {
+		return err
+	}
+
+	for _, node := range nodes {
+		var topics []common.RelatedTopic
+
+		if node == nil {
+			continue
+		}
+
+		if err = w.Write(&DumpRecord{Type: DumpNodeRecord, ID: node.ID, Node: node}); err != nil {
+			return err
+		}
+
+		stats.Nodes++
+
+		if topics, err = r.GetTopics(node); err != nil {
+			if isNotFound(err) {
+				continue
+			}
+			return err
+		}
+
+		if err = w.Write(&DumpRecord{Type: DumpTopicsRecord, ID: node.ID, Topics: topics}); err != nil {
+			return err
+		}
+
+		stats.Topics++
+	}
+
+	for vocabulary := range page.About {
+		vocabularies = append(vocabularies, vocabulary)
+	}
+
+	sort.Strings(vocabularies)
+
+	for _, vocabulary := range vocabularies {
+		var about *common.Thing
+
+		if about, err = r.GetAbout(page.About[vocabulary]); err != nil {
+			if isNotFound(err) {
+				continue
+			}
+			return err
+		}
+
+		if err = w.Write(&DumpRecord{Type: DumpAboutRecord, ID: about.ID, About: about}); err != nil {
+			return err
+		}
+
+		stats.Abouts++
+	}
+
+	return nil
+}
+
+// ImportOptions configure an Import
+type ImportOptions struct {
+	// Overwrite Pages whose stored revision is newer than that of the dump
+	Force bool
+}
+
+// A Page of a dump, and the objects that follow it
+type dumpedPage struct {
+	page   *common.Page
+	nodes  []common.Node
+	abouts map[string]*common.Thing
+	topics map[string][]common.RelatedTopic
+}
+
+// Import loads a dump (see: Export) into the repository.  Each Page is applied (see: Apply) along with its
+// Nodes and linked-data objects, so the Index is updated as well; Related topics are stored after, and added to
+// TopicSearch (if any).  Page and Node IDs are preserved, and verified; A dump whose IDs do not match those this
+// version of Repository would generate is rejected.
+//
+// Pages whose stored version has the same source revision (and render), and whose Nodes are all stored and
+// unchanged, are skipped (as are related topics identical to those stored); So are Pages whose stored revision
+// is newer (along with their related topics), unless options.Force is set.
+func (r *Repository) Import(reader DumpReader, options ImportOptions) (*DumpStats, error) {
+	var current *dumpedPage
+	var record *DumpRecord
+	var stats = &DumpStats{}
+	var err error
+
+	if record, err = reader.Read(); err != nil {
+		if err == io.EOF {
+			return stats, errors.New("unable to read dump: empty")
+		}
+		return stats, err
+	}
+
+	if record.Type != DumpHeaderRecord || record.Header == nil {
+		return stats, errors.New("unable to read dump: missing header")
+	}
+
+	if record.Header.Version < 1 || record.Header.Version > DumpVersion {
+		return stats, fmt.Errorf("unable to read dump: unsupported version %d (the latest supported is %d)", record.Header.Version, DumpVersion)
+	}
+
+	for {
+		if record, err = reader.Read(); err != nil && err != io.EOF {
+			return stats, err
+		}
+
+		// The objects of a page end at the next page (or the end of the dump)
+		if current != nil && (err == io.EOF || record.Type == DumpPageRecord) {
+			if err := r.importPage(current, options, stats); err != nil {
+				return stats, fmt.Errorf("error importing %s: %w", current.page.ID, err)
+			}
+			current = nil
+		}
+
+		if err == io.EOF {
+			return stats, nil
+		}
+
+		if record.Type == DumpPageRecord {
+			if record.Page == nil {
+				return stats, fmt.Errorf("malformed %s record: %s", record.Type, record.ID)
+			}
+			current = &dumpedPage{
+				page:   record.Page,
+				abouts: make(map[string]*common.Thing),
+				topics: make(map[string][]common.RelatedTopic),
+			}
+			continue
+		}
+
+		if current == nil {
+			return stats, fmt.Errorf("unable to read dump: %s record %s precedes the first page", record.Type, record.ID)
+		}
+
+		switch {
+		case record.Type == DumpNodeRecord && record.Node != nil:
+			current.nodes = append(current.nodes, *record.Node)
+		case record.Type == DumpAboutRecord && record.About != nil:
+			current.abouts[record.ID] = record.About
+		case record.Type == DumpTopicsRecord:
+			current.topics[record.ID] = record.Topics
+		default:
+			return stats, fmt.Errorf("malformed %s record: %s", record.Type, record.ID)
+		}
+	}
+}
+
+func (r *Repository) importPage(dumped *dumpedPage, options ImportOptions, stats *DumpStats) error {
+	var page = *dumped.page
+	var stored *common.Page
+	var update = &Update{Page: page, Abouts: make(map[string]common.Thing), Force: options.Force}
+	var upToDate bool
+	var err error
+
+	if id := pagef(makePageID(&page)); id != dumped.page.ID {
+		return fmt.Errorf("page ID mismatch (expected %s)", id)
+	}
+
+	for _, node := range dumped.nodes {
+		// Apply assigns Nodes the Source of their Page
+		node.Source = page.Source
+
+		if id := nodef(makeNodeID(&node)); id != node.ID {
+			return fmt.Errorf("node %s ID mismatch (expected %s)", node.ID, id)
+		}
+
+		update.Nodes = append(update.Nodes, node)
+	}
+
+	for vocabulary, id := range page.About {
+		if about, ok := dumped.abouts[id]; ok {
+			update.Abouts[vocabulary] = *about
+		}
+	}
+
+	if stored, err = r.GetPage(page.ID); err != nil && !isNotFound(err) {
+		return err
+	}
+
+	if stored != nil {
+		if isOlder(page.Source, stored.Source) && !options.Force {
+			stats.Skipped++
+			return nil
+		}
+
+		if stored.Source == page.Source {
+			if upToDate, err = r.areUnchanged(update.Nodes, stored); err != nil {
+				return err
+			}
+		}
+	}
+
+	if upToDate {
+		stats.Skipped++
+	} else {
+		if _, err = r.Apply(update); err != nil {
+			return err
+		}
+
+		stats.Pages++
+		stats.Nodes += len(update.Nodes)
+		stats.Abouts += len(update.Abouts)
+	}
+
+	for _, node := range update.Nodes {
+		var existing []common.RelatedTopic
+
+		topics, ok := dumped.topics[node.ID]
+		if !ok {
+			continue
+		}
+
+		// Related topics are (expensive to come by, and) unversioned; Only write them if they differ.
+		if existing, err = r.GetTopics(&node); err != nil && !isNotFound(err) {
+			return err
+		}
+
+		if existing != nil && reflect.DeepEqual(existing, topics) {
+			continue
+		}
+
+		if err = r.PutTopics(&node, topics); err != nil {
+			return err
+		}
+
+		if r.TopicSearch != nil {
+			if _, err = r.TopicSearch.Update(&node, topics); err != nil {
+				return fmt.Errorf("error updating topic search: %w", err)
+			}
+		}
+
+		stats.Topics++
+	}
+
+	return nil
+}
+
+// Returns true if every node is published as a part of page, with the same content.
+func (r *Repository) areUnchanged(nodes []common.Node, page *common.Page) (bool, error) {
+	for _, node := range nodes {
+		node.ContentHash = makeContentHash(&node)
+
+		if unchanged, err := r.isUnchanged(&node, page); err != nil || !unchanged {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func isNotFound(err error) bool {
+	var nerr *ErrNotFound
+	return errors.As(err, &nerr)
+}
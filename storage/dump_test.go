@@ -0,0 +1,280 @@
+package storage
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wikimedia/phoenix/common"
+)
+
+// Returns a repository of pages modified on successive days (beginning with start); The last is of another wiki.
+func newDumpTestRepository(t *testing.T, start time.Time) *Repository {
+	repo := &Repository{Store: NewMockStore(), Index: NewMockIndex(), Bucket: "test"}
+
+	for i := 0; i < 3; i++ {
+		page := testPage
+		page.Source.ID = 100 + i
+		page.Name = fmt.Sprintf("Page %d", i)
+		page.DateModified = start.AddDate(0, 0, i)
+
+		if i == 2 {
+			page.Source.Authority = "other.wikipedia.org"
+		}
+
+		history := testNode
+		history.Source = page.Source
+		geography := history
+		geography.Name = "Geography"
+
+		_, err := repo.Apply(&Update{
+			Page:   page,
+			Nodes:  []common.Node{history, geography},
+			Abouts: map[string]common.Thing{"//schema.org": testAbout},
+		})
+		require.Nil(t, err)
+		require.Nil(t, repo.PutTopics(&history, testTopics))
+	}
+
+	return repo
+}
+
+// Parses a newline-delimited JSON dump
+func readJSONDump(t *testing.T, data []byte) []DumpRecord {
+	var records []DumpRecord
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+
+	for scanner.Scan() {
+		var record DumpRecord
+		require.Nil(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+
+	require.Nil(t, scanner.Err())
+
+	return records
+}
+
+func TestRepositoryExport(t *testing.T) {
+	start := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	repo := newDumpTestRepository(t, start)
+
+	export := func(options ExportOptions) ([]byte, *DumpStats) {
+		var buf bytes.Buffer
+		stats, err := repo.Export(NewJSONDumpWriter(&buf), options)
+		require.Nil(t, err)
+		return buf.Bytes(), stats
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		data, stats := export(ExportOptions{})
+		assert.Equal(t, &DumpStats{Pages: 3, Nodes: 6, Abouts: 3, Topics: 3}, stats)
+
+		records := readJSONDump(t, data)
+		require.Len(t, records, 16)
+
+		assert.Equal(t, DumpHeaderRecord, records[0].Type)
+		assert.Equal(t, DumpVersion, records[0].Header.Version)
+
+		// Each page, followed by its nodes (and their topics), and linked data
+		var types []string
+		for _, record := range records[1:6] {
+			types = append(types, record.Type)
+		}
+		assert.Equal(t, []string{DumpPageRecord, DumpNodeRecord, DumpTopicsRecord, DumpNodeRecord, DumpAboutRecord}, types)
+
+		page := records[1].Page
+		assert.Equal(t, page.ID, records[1].ID)
+		assert.Equal(t, page.HasPart[0], records[2].Node.ID)
+		assert.Equal(t, page.HasPart[0], records[3].ID)
+		assert.Equal(t, testTopics, records[3].Topics)
+		assert.Equal(t, page.About["//schema.org"], records[5].ID)
+		assert.Equal(t, testAbout.SameAs, records[5].About.SameAs)
+
+		// Pages in order of ID
+		assert.True(t, records[1].ID < records[6].ID)
+		assert.True(t, records[6].ID < records[11].ID)
+	})
+
+	t.Run("Deterministic", func(t *testing.T) {
+		a, _ := export(ExportOptions{})
+		b, _ := export(ExportOptions{})
+
+		// Identical, save for the header
+		assert.Equal(t, a[bytes.IndexByte(a, '\n'):], b[bytes.IndexByte(b, '\n'):])
+	})
+
+	t.Run("Filtering", func(t *testing.T) {
+		data, stats := export(ExportOptions{Authority: testPage.Source.Authority, ModifiedSince: start.AddDate(0, 0, 1)})
+		assert.Equal(t, &DumpStats{Pages: 1, Nodes: 2, Abouts: 1, Topics: 1}, stats)
+
+		records := readJSONDump(t, data)
+		assert.Equal(t, testPage.Source.Authority, records[0].Header.Authority)
+		assert.Equal(t, start.AddDate(0, 0, 1), *records[0].Header.ModifiedSince)
+		assert.Equal(t, "Page 1", records[1].Page.Name)
+	})
+
+	t.Run("Tar", func(t *testing.T) {
+		var buf bytes.Buffer
+		var names []string
+
+		w := NewTarDumpWriter(&buf)
+		_, err := repo.Export(w, ExportOptions{Authority: "other.wikipedia.org"})
+		require.Nil(t, err)
+		require.Nil(t, w.Close())
+
+		r := tar.NewReader(&buf)
+		for {
+			header, err := r.Next()
+			if err == io.EOF {
+				break
+			}
+			require.Nil(t, err)
+			names = append(names, header.Name)
+		}
+
+		require.Len(t, names, 6)
+		assert.Equal(t, "header.json", names[0])
+		assert.Regexp(t, `^page/[0-9a-f]+\.json$`, names[1])
+		assert.Regexp(t, `^node/[0-9a-f]+\.json$`, names[2])
+		assert.Regexp(t, `^topics/[0-9a-f]+\.json$`, names[3])
+		assert.Regexp(t, `^about/.+\.json$`, names[5])
+	})
+}
+
+func TestRepositoryImport(t *testing.T) {
+	start := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	source := newDumpTestRepository(t, start)
+
+	dump := func(t *testing.T, format string) []byte {
+		var buf bytes.Buffer
+		var compressor *gzip.Writer
+		var w DumpWriter
+
+		switch format {
+		case "ndjson":
+			w = NewJSONDumpWriter(&buf)
+		case "tar":
+			w = NewTarDumpWriter(&buf)
+		case "tar.gz":
+			compressor = gzip.NewWriter(&buf)
+			w = NewTarDumpWriter(compressor)
+		}
+
+		_, err := source.Export(w, ExportOptions{})
+		require.Nil(t, err)
+		require.Nil(t, w.Close())
+
+		if compressor != nil {
+			require.Nil(t, compressor.Close())
+		}
+
+		return buf.Bytes()
+	}
+
+	load := func(t *testing.T, repo *Repository, data []byte, options ImportOptions) *DumpStats {
+		reader, err := NewDumpReader(bytes.NewReader(data))
+		require.Nil(t, err)
+		stats, err := repo.Import(reader, options)
+		require.Nil(t, err)
+		return stats
+	}
+
+	for _, format := range []string{"ndjson", "tar", "tar.gz"} {
+		t.Run(format, func(t *testing.T) {
+			topicSearch := NewMockTopicSearch()
+			repo := &Repository{Store: NewMockStore(), Index: NewMockIndex(), Bucket: "test", TopicSearch: topicSearch}
+
+			data := dump(t, format)
+
+			stats := load(t, repo, data, ImportOptions{})
+			assert.Equal(t, &DumpStats{Pages: 3, Nodes: 6, Abouts: 3, Topics: 3}, stats)
+
+			// IDs are preserved, and names indexed
+			expected, _, err := source.ListPages("", "", 0)
+			require.Nil(t, err)
+
+			for _, want := range expected {
+				page, err := repo.GetPageByName(want.Source.Authority, want.Name)
+				require.Nil(t, err)
+				assert.Equal(t, want.ID, page.ID)
+				assert.Equal(t, want.HasPart, page.HasPart)
+				assert.Equal(t, want.DateModified, page.DateModified)
+
+				node, err := repo.GetNodeByName(want.Source.Authority, want.Name, testNode.Name)
+				require.Nil(t, err)
+				assert.Equal(t, want.HasPart[0], node.ID)
+
+				topics, err := repo.GetTopics(node)
+				require.Nil(t, err)
+				assert.Equal(t, testTopics, topics)
+
+				about, err := repo.GetAbout(page.About["//schema.org"])
+				require.Nil(t, err)
+				assert.Equal(t, testAbout.SameAs, about.SameAs)
+			}
+
+			ids, err := topicSearch.Search(testTopics[0].ID)
+			require.Nil(t, err)
+			assert.Len(t, ids, 3)
+
+			// Everything is up to date
+			stats = load(t, repo, data, ImportOptions{})
+			assert.Equal(t, &DumpStats{Skipped: 3}, stats)
+		})
+	}
+
+	t.Run("Newer", func(t *testing.T) {
+		repo := &Repository{Store: NewMockStore(), Index: NewMockIndex(), Bucket: "test"}
+
+		page := testPage
+		page.Source.ID = 100
+		page.Source.Revision = 2
+		page.Name = "Page 0"
+
+		_, err := repo.Apply(&Update{Page: page, Nodes: []common.Node{testNode}})
+		require.Nil(t, err)
+
+		data := dump(t, "ndjson")
+
+		stats := load(t, repo, data, ImportOptions{})
+		assert.Equal(t, 2, stats.Pages)
+		assert.Equal(t, 1, stats.Skipped)
+
+		stats = load(t, repo, data, ImportOptions{Force: true})
+		assert.Equal(t, 1, stats.Pages)
+		assert.Equal(t, 2, stats.Skipped)
+
+		stored, err := repo.GetPageByName(page.Source.Authority, page.Name)
+		require.Nil(t, err)
+		assert.Equal(t, 1, stored.Source.Revision)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		repo := &Repository{Store: NewMockStore(), Index: NewMockIndex(), Bucket: "test"}
+
+		for name, data := range map[string]string{
+			"Empty":          "",
+			"No header":      `{"type":"page","id":"/page/1","page":{}}`,
+			"Newer version":  fmt.Sprintf(`{"type":"header","header":{"version":%d}}`, DumpVersion+1),
+			"Orphan record":  `{"type":"header","header":{"version":1}}` + "\n" + `{"type":"node","id":"/node/1","node":{}}`,
+			"ID mismatch":    `{"type":"header","header":{"version":1}}` + "\n" + `{"type":"page","id":"/page/1","page":{"identifier":"/page/1"}}`,
+			"Malformed JSON": `{"type":"header","header":{"version":1}}` + "\n" + `{"type":`,
+		} {
+			reader, err := NewDumpReader(bytes.NewReader([]byte(data)))
+			require.Nil(t, err)
+			_, err = repo.Import(reader, ImportOptions{})
+			assert.NotNil(t, err, name)
+		}
+	})
+}
@@ -0,0 +1,219 @@
+package storage
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+)
+
+// Repository and Index methods return errors of the types below (wrapped, so use errors.As) for each of the
+// failures a caller might act upon; Errors of the underlying services (awserr.Error, and so on) are mapped to
+// these, and remain available through errors.Unwrap.  Errors of any other kind are not expected to succeed on
+// retry.
+
+// ErrNotFound indicates that a requested resource does not exist in storage
+type ErrNotFound struct {
+	message string
+}
+
+func (e *ErrNotFound) Error() string {
+	return e.message
+}
+
+// ErrInvalid indicates that a request was rejected as invalid (an object missing required attributes, for
+// example); It will not succeed on retry.
+type ErrInvalid struct {
+	message string
+	err     error
+}
+
+func (e *ErrInvalid) Error() string {
+	return e.message
+}
+
+func (e *ErrInvalid) Unwrap() error {
+	return e.err
+}
+
+// ErrConflict indicates that a request conflicts with the state of storage (a concurrent update, for example).
+type ErrConflict struct {
+	message string
+	err     error
+}
+
+func (e *ErrConflict) Error() string {
+	return e.message
+}
+
+func (e *ErrConflict) Unwrap() error {
+	return e.err
+}
+
+// ErrStaleRevision indicates that an update is older than the revision already stored.  It is a kind of
+// ErrConflict (errors.As matches either).
+type ErrStaleRevision struct {
+	message string
+}
+
+func (e *ErrStaleRevision) Error() string {
+	return e.message
+}
+
+func (e *ErrStaleRevision) Unwrap() error {
+	return &ErrConflict{message: e.message}
+}
+
+// ErrTransient indicates a failure that is expected to clear on its own (a timeout, a dropped connection, or
+// a service error); The request can be retried.
+type ErrTransient struct {
+	message string
+	err     error
+}
+
+func (e *ErrTransient) Error() string {
+	return e.message
+}
+
+func (e *ErrTransient) Unwrap() error {
+	return e.err
+}
+
+// ErrQuota indicates that a request was throttled, or that a capacity limit was exceeded; The request can be
+// retried, after backing off.
+type ErrQuota struct {
+	message string
+	err     error
+}
+
+func (e *ErrQuota) Error() string {
+	return e.message
+}
+
+func (e *ErrQuota) Unwrap() error {
+	return e.err
+}
+
+// IsRetryable returns true if err is one of the errors that a request can be retried after (ErrTransient,
+// ErrQuota, or ErrPartialIndex).
+func IsRetryable(err error) bool {
+	var terr *ErrTransient
+	var qerr *ErrQuota
+	var perr *ErrPartialIndex
+
+	return errors.As(err, &terr) || errors.As(err, &qerr) || errors.As(err, &perr)
+}
+
+// Error codes of AWS services, by the storage error they correspond to (those of throttling, and transient
+// failures are recognized by the SDK; See: request.IsErrorThrottle and request.IsErrorRetryable).
+var (
+	notFoundCodes = map[string]bool{
+		s3.ErrCodeNoSuchKey: true,
+		"NotFound":          true,
+	}
+	invalidCodes = map[string]bool{
+		"EntityTooLarge":                true,
+		"InvalidArgument":               true,
+		"InvalidRequest":                true,
+		"KeyTooLongError":               true,
+		"MetadataTooLarge":              true,
+		"SerializationException":        true,
+		"ValidationException":           true,
+		request.InvalidParameterErrCode: true,
+		request.ParamRequiredErrCode:    true,
+	}
+	conflictCodes = map[string]bool{
+		dynamodb.ErrCodeConditionalCheckFailedException: true,
+		dynamodb.ErrCodeTransactionConflictException:    true,
+		dynamodb.ErrCodeTransactionCanceledException:    true,
+		"OperationAborted": true,
+	}
+	quotaCodes = map[string]bool{
+		dynamodb.ErrCodeItemCollectionSizeLimitExceededException: true,
+		dynamodb.ErrCodeLimitExceededException:                   true,
+		"SlowDown":                                               true,
+	}
+)
+
+// Returns err (the error of a request for what) as the corresponding storage error; Errors already of a storage
+// type are returned as-is, and those of no other type are annotated with what.
+func classify(err error, what string) error {
+	var aerr awserr.Error
+	var nerr net.Error
+
+	if err == nil || isClassified(err) {
+		return err
+	}
+
+	message := fmt.Sprintf("%s: %s", what, err)
+
+	// Requests abandoned once their context is done (see: Repository.WithContext) fail with the context's error
+	if errors.As(err, &aerr) && aerr.Code() == request.CanceledErrorCode && aerr.OrigErr() != nil {
+		return classify(aerr.OrigErr(), what)
+	}
+
+	if errors.As(err, &aerr) {
+		var status int
+		var rerr awserr.RequestFailure
+
+		if errors.As(err, &rerr) {
+			status = rerr.StatusCode()
+		}
+
+		switch code := aerr.Code(); {
+		case notFoundCodes[code]:
+			return &ErrNotFound{fmt.Sprintf("%s not found", what)}
+		case invalidCodes[code]:
+			return &ErrInvalid{message, err}
+		case conflictCodes[code] || status == http.StatusConflict:
+			return &ErrConflict{message, err}
+		case quotaCodes[code] || request.IsErrorThrottle(aerr) || status == http.StatusTooManyRequests:
+			return &ErrQuota{message, err}
+		case request.IsErrorRetryable(aerr) || status >= http.StatusInternalServerError:
+			return &ErrTransient{message, err}
+		}
+	}
+
+	if errors.As(err, &nerr) || errors.Is(err, driver.ErrBadConn) || errors.Is(err, context.DeadlineExceeded) {
+		return &ErrTransient{message, err}
+	}
+
+	return fmt.Errorf("%s: %w", what, err)
+}
+
+// Returns the storage error corresponding to an (error) response of Elasticsearch to a request for what.
+func classifyResponse(res *esapi.Response, what string) error {
+	var message = fmt.Sprintf("%s (status=%s)", what, res.Status())
+
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return &ErrNotFound{fmt.Sprintf("%s not found", what)}
+	case res.StatusCode == http.StatusBadRequest:
+		return &ErrInvalid{message: message}
+	case res.StatusCode == http.StatusConflict:
+		return &ErrConflict{message: message}
+	case res.StatusCode == http.StatusTooManyRequests:
+		return &ErrQuota{message: message}
+	case res.StatusCode >= http.StatusInternalServerError:
+		return &ErrTransient{message: message}
+	}
+
+	return errors.New(message)
+}
+
+// Returns true if err is (or wraps) one of the storage error types.
+func isClassified(err error) bool {
+	var nerr *ErrNotFound
+	var ierr *ErrInvalid
+	var cerr *ErrConflict
+
+	return errors.As(err, &nerr) || errors.As(err, &ierr) || errors.As(err, &cerr) || IsRetryable(err)
+}
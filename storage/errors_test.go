@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wikimedia/phoenix/common"
+)
+
+func TestClassify(t *testing.T) {
+	var (
+		notFound  *ErrNotFound
+		invalid   *ErrInvalid
+		conflict  *ErrConflict
+		transient *ErrTransient
+		quota     *ErrQuota
+	)
+
+	tests := []struct {
+		name   string
+		err    error
+		target interface{}
+	}{
+		{"No such key", awserr.New(s3.ErrCodeNoSuchKey, "not found", nil), &notFound},
+		{"Validation", awserr.New("ValidationException", "invalid", nil), &invalid},
+		{"Conditional check", awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "failed", nil), &conflict},
+		{"Conflict status", awserr.NewRequestFailure(awserr.New("Conflict", "conflict", nil), 409, "1"), &conflict},
+		{"Throttled", awserr.New(dynamodb.ErrCodeProvisionedThroughputExceededException, "throttled", nil), &quota},
+		{"Slow down", awserr.NewRequestFailure(awserr.New("SlowDown", "slow down", nil), 503, "1"), &quota},
+		{"Timeout", awserr.New("RequestTimeout", "timeout", nil), &transient},
+		{"Service error", awserr.NewRequestFailure(awserr.New("InternalError", "oops", nil), 500, "1"), &transient},
+		{"Network", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, &transient},
+		{"Bad connection", fmt.Errorf("query failed: %w", driver.ErrBadConn), &transient},
+		{"Deadline", awserr.New(request.CanceledErrorCode, "request context canceled", context.DeadlineExceeded), &transient},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := classify(test.err, "test")
+			assert.True(t, errors.As(err, test.target), "Unexpected error type: %T", err)
+		})
+	}
+
+	t.Run("Unclassified", func(t *testing.T) {
+		cause := awserr.New("AccessDenied", "denied", nil)
+		err := classify(cause, "test")
+		assert.False(t, isClassified(err))
+		assert.False(t, IsRetryable(err))
+		assert.True(t, errors.Is(err, cause))
+	})
+
+	t.Run("Classified", func(t *testing.T) {
+		cause := &ErrInvalid{message: "invalid"}
+		assert.Equal(t, cause, classify(cause, "test"))
+	})
+
+	t.Run("Cause", func(t *testing.T) {
+		cause := awserr.New(dynamodb.ErrCodeProvisionedThroughputExceededException, "throttled", nil)
+		err := classify(cause, "test")
+		assert.True(t, IsRetryable(err))
+
+		var aerr awserr.Error
+		require.True(t, errors.As(err, &aerr))
+		assert.Equal(t, dynamodb.ErrCodeProvisionedThroughputExceededException, aerr.Code())
+	})
+}
+
+func TestRepositoryErrors(t *testing.T) {
+	store := &instrumentedStore{MockStore: NewMockStore()}
+	repo := &Repository{Store: store, Index: NewMockIndex(), Bucket: "testing"}
+
+	t.Run("Not found", func(t *testing.T) {
+		var nerr *ErrNotFound
+
+		_, err := repo.GetPage("/page/bogus")
+		assert.True(t, errors.As(err, &nerr), "Expected an error of type ErrNotFound")
+
+		_, err = repo.GetNode("/node/bogus")
+		assert.True(t, errors.As(err, &nerr), "Expected an error of type ErrNotFound")
+
+		_, err = repo.GetPageByName("fake.wikipedia.org", "Bogus")
+		assert.True(t, errors.As(err, &nerr), "Expected an error of type ErrNotFound")
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		var ierr *ErrInvalid
+
+		_, err := repo.Apply(&Update{Page: common.Page{Name: "Invalid"}})
+		assert.True(t, errors.As(err, &ierr), "Expected an error of type ErrInvalid")
+		assert.False(t, IsRetryable(err))
+	})
+
+	t.Run("Retryable", func(t *testing.T) {
+		var qerr *ErrQuota
+
+		store.getError = awserr.NewRequestFailure(awserr.New("SlowDown", "Please reduce your request rate.", nil), 503, "1")
+		defer func() { store.getError = nil }()
+
+		_, err := repo.GetPage("/page/bogus")
+		assert.True(t, errors.As(err, &qerr), "Expected an error of type ErrQuota")
+		assert.True(t, IsRetryable(err))
+	})
+
+	t.Run("Stale", func(t *testing.T) {
+		var cerr *ErrConflict
+		var serr *ErrStaleRevision
+
+		err := fmt.Errorf("apply failed: %w", &ErrStaleRevision{message: "stale"})
+		assert.True(t, errors.As(err, &serr), "Expected an error of type ErrStaleRevision")
+		assert.True(t, errors.As(err, &cerr), "Expected an error of type ErrConflict")
+	})
+}
@@ -0,0 +1,344 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+const (
+	// Suffixes appended to object keys to form the names of object data, and object metadata (sidecar) files.
+	fileStoreDataSuffix = ".data"
+	fileStoreMetaSuffix = ".meta"
+)
+
+// FileStore is a Store backed by a local directory tree.  Objects are stored beneath Root (in a directory per
+// bucket), with each object's content type and user metadata kept in a JSON-encoded sidecar file.
+type FileStore struct {
+	Root string
+}
+
+// Object metadata, as serialized to sidecar files
+type fileStoreMeta struct {
+	ContentType string             `json:"contentType,omitempty"`
+	Metadata    map[string]*string `json:"metadata,omitempty"`
+}
+
+// PutObject stores an object (see: s3.S3#PutObject)
+func (s *FileStore) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	var data, meta []byte
+	var path string
+	var err error
+
+	if path, err = s.path(input.Bucket, input.Key); err != nil {
+		return nil, err
+	}
+
+	if data, err = ioutil.ReadAll(input.Body); err != nil {
+		return nil, fmt.Errorf("unable to read object body: %w", err)
+	}
+
+	if meta, err = json.Marshal(&fileStoreMeta{ContentType: aws.StringValue(input.ContentType), Metadata: input.Metadata}); err != nil {
+		return nil, fmt.Errorf("unable to marshal object metadata: %w", err)
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("unable to create directory: %w", err)
+	}
+
+	// The sidecar is written first; An object is visible once its data file exists.
+	if err = writeFileAtomic(path+fileStoreMetaSuffix, meta); err != nil {
+		return nil, err
+	}
+
+	if err = writeFileAtomic(path+fileStoreDataSuffix, data); err != nil {
+		return nil, err
+	}
+
+	return &s3.PutObjectOutput{}, nil
+}
+
+// GetObject retrieves an object (see: s3.S3#GetObject).  Like S3, a request for a key that does not exist
+// returns an awserr.Error with a code of s3.ErrCodeNoSuchKey.
+func (s *FileStore) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	var data, b []byte
+	var info os.FileInfo
+	var meta fileStoreMeta
+	var path string
+	var err error
+
+	if path, err = s.path(input.Bucket, input.Key); err != nil {
+		return nil, err
+	}
+
+	if data, err = ioutil.ReadFile(path + fileStoreDataSuffix); err != nil {
+		if os.IsNotExist(err) {
+			return nil, awserr.New(s3.ErrCodeNoSuchKey, fmt.Sprintf("%s not found", aws.StringValue(input.Key)), err)
+		}
+		return nil, fmt.Errorf("unable to read object: %w", err)
+	}
+
+	if b, err = ioutil.ReadFile(path + fileStoreMetaSuffix); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("unable to read object metadata: %w", err)
+	}
+
+	if len(b) > 0 {
+		if err = json.Unmarshal(b, &meta); err != nil {
+			return nil, fmt.Errorf("unable to deserialize object metadata: %w", err)
+		}
+	}
+
+	output := &s3.GetObjectOutput{
+		Body:          ioutil.NopCloser(bytes.NewReader(data)),
+		ContentLength: aws.Int64(int64(len(data))),
+		Metadata:      meta.Metadata,
+	}
+
+	if meta.ContentType != "" {
+		output.ContentType = aws.String(meta.ContentType)
+	}
+
+	if info, err = os.Stat(path + fileStoreDataSuffix); err == nil {
+		output.LastModified = aws.Time(info.ModTime().UTC().Truncate(time.Second))
+	}
+
+	return output, nil
+}
+
+// DeleteObjects removes objects (see: s3.S3#DeleteObjects).  Like S3, deleting a key that does not exist is
+// not an error, and failures are reported per-key in the output.
+func (s *FileStore) DeleteObjects(input *s3.DeleteObjectsInput) (*s3.DeleteObjectsOutput, error) {
+	var output = &s3.DeleteObjectsOutput{}
+
+	for _, object := range input.Delete.Objects {
+		var path string
+		var err error
+
+		if path, err = s.path(input.Bucket, object.Key); err == nil {
+			if err = os.Remove(path + fileStoreDataSuffix); err == nil || os.IsNotExist(err) {
+				err = os.Remove(path + fileStoreMetaSuffix)
+			}
+		}
+
+		if err != nil && !os.IsNotExist(err) {
+			output.Errors = append(output.Errors, &s3.Error{Key: object.Key, Code: aws.String("InternalError"), Message: aws.String(err.Error())})
+			continue
+		}
+
+		if !aws.BoolValue(input.Delete.Quiet) {
+			output.Deleted = append(output.Deleted, &s3.DeletedObject{Key: object.Key})
+		}
+	}
+
+	return output, nil
+}
+
+// ListObjectsV2 lists objects (see: s3.S3#ListObjectsV2).  Like S3, keys are listed in lexicographical order,
+// and the continuation token is opaque (it is the last key listed).  NOTE: Keys are listed with a leading
+// slash (as the Repository stores them), regardless of whether or not they were stored with one.
+//
+// Only the directory of the prefix is walked, in key order, skipping the subdirectories that precede the
+// cursor, and stopping once a page of keys (and one more, to tell if there are others) has been collected.
+func (s *FileStore) ListObjectsV2(input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+	var base = filepath.Join(s.Root, aws.StringValue(input.Bucket))
+	var prefix = aws.StringValue(input.Prefix)
+	var after = aws.StringValue(input.StartAfter)
+	var limit = int(aws.Int64Value(input.MaxKeys))
+	var dir, start string
+	var keys []string
+	var err error
+
+	if token := aws.StringValue(input.ContinuationToken); token > after {
+		after = token
+	}
+
+	if limit <= 0 || limit > maxListKeys {
+		limit = maxListKeys
+	}
+
+	// Keys are listed with a leading slash; The directory of the prefix is that of its last one.
+	dir = "/" + strings.TrimPrefix(prefix[:strings.LastIndex(prefix, "/")+1], "/")
+
+	if start, err = s.path(input.Bucket, aws.String(dir)); err != nil {
+		if dir != "/" {
+			return nil, err
+		}
+		start = base
+	}
+
+	var walk func(path, key string) error
+
+	walk = func(path, key string) error {
+		var entries []fileStoreEntry
+		var infos []os.FileInfo
+		var err error
+
+		// A bucket (or directory) that has never been written to is empty
+		if infos, err = ioutil.ReadDir(path); err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		for _, info := range infos {
+			switch {
+			case info.IsDir():
+				entries = append(entries, fileStoreEntry{key: key + info.Name() + "/", path: filepath.Join(path, info.Name()), dir: true})
+			case strings.HasSuffix(info.Name(), fileStoreDataSuffix):
+				entries = append(entries, fileStoreEntry{key: key + strings.TrimSuffix(info.Name(), fileStoreDataSuffix)})
+			}
+		}
+
+		// Directories sort as the keys beneath them do (by name, followed by a slash)
+		sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+
+		for _, entry := range entries {
+			if len(keys) > limit {
+				return nil
+			}
+
+			if !entry.dir {
+				if strings.HasPrefix(entry.key, prefix) && entry.key > after {
+					keys = append(keys, entry.key)
+				}
+				continue
+			}
+
+			// Skip directories outside of the prefix, and those whose keys all precede the cursor
+			if !strings.HasPrefix(entry.key, prefix) && !strings.HasPrefix(prefix, entry.key) {
+				continue
+			}
+
+			if entry.key < after && !strings.HasPrefix(after, entry.key) {
+				continue
+			}
+
+			if err = walk(entry.path, entry.key); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if err = walk(start, dir); err != nil {
+		return nil, fmt.Errorf("unable to list objects: %w", err)
+	}
+
+	return listObjectsV2(keys, input), nil
+}
+
+// An entry of a FileStore directory; Either an object, or a directory of objects
+type fileStoreEntry struct {
+	key  string
+	path string
+	dir  bool
+}
+
+// Lists keys in the manner of s3.S3#ListObjectsV2 (for Store implementations that hold their keys in memory).
+func listObjectsV2(keys []string, input *s3.ListObjectsV2Input) *s3.ListObjectsV2Output {
+	var after = aws.StringValue(input.StartAfter)
+	var limit = int(aws.Int64Value(input.MaxKeys))
+	var output = &s3.ListObjectsV2Output{Name: input.Bucket, Prefix: input.Prefix, IsTruncated: aws.Bool(false)}
+
+	if token := aws.StringValue(input.ContinuationToken); token > after {
+		after = token
+	}
+
+	if limit <= 0 || limit > maxListKeys {
+		limit = maxListKeys
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if !strings.HasPrefix(key, aws.StringValue(input.Prefix)) || key <= after {
+			continue
+		}
+
+		if len(output.Contents) == limit {
+			output.IsTruncated = aws.Bool(true)
+			output.NextContinuationToken = output.Contents[limit-1].Key
+			break
+		}
+
+		output.Contents = append(output.Contents, &s3.Object{Key: aws.String(key)})
+	}
+
+	output.KeyCount = aws.Int64(int64(len(output.Contents)))
+
+	return output
+}
+
+// Maps a bucket and key to a (suffix-less) filesystem path, making certain the result does not escape Root.
+func (s *FileStore) path(bucket, key *string) (string, error) {
+	var base = filepath.Join(s.Root, aws.StringValue(bucket))
+	var path = filepath.Join(base, filepath.FromSlash(aws.StringValue(key)))
+
+	if path == base || !strings.HasPrefix(path, base+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid object key: %q", aws.StringValue(key))
+	}
+
+	return path, nil
+}
+
+// Writes a file by way of a temporary file and a rename, so that readers never observe partial writes.
+func writeFileAtomic(name string, data []byte) error {
+	var file *os.File
+	var err error
+
+	if file, err = ioutil.TempFile(filepath.Dir(name), ".tmp-"); err != nil {
+		return fmt.Errorf("unable to create temporary file: %w", err)
+	}
+
+	if _, err = file.Write(data); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return fmt.Errorf("unable to write %s: %w", name, err)
+	}
+
+	if err = file.Close(); err != nil {
+		os.Remove(file.Name())
+		return fmt.Errorf("unable to write %s: %w", name, err)
+	}
+
+	if err = os.Rename(file.Name(), name); err != nil {
+		os.Remove(file.Name())
+		return fmt.Errorf("unable to write %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// LocalIndexPath returns the path of the BoltIndex of a local Repository (see: NewLocalRepository)
+func LocalIndexPath(dir string) string {
+	return filepath.Join(dir, "index.db")
+}
+
+// NewLocalRepository returns a Repository that requires no AWS resources; Objects are stored in a FileStore
+// rooted at dir, and indexed using a BoltIndex (dir/index.db).
+func NewLocalRepository(dir, bucket string) (*Repository, error) {
+	var index *BoltIndex
+	var err error
+
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("unable to create directory: %w", err)
+	}
+
+	if index, err = NewBoltIndex(LocalIndexPath(dir)); err != nil {
+		return nil, err
+	}
+
+	return &Repository{Store: &FileStore{Root: dir}, Index: index, Bucket: bucket}, nil
+}
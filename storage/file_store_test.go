@@ -0,0 +1,177 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wikimedia/phoenix/common"
+)
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phoenix-store")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	store := &FileStore{Root: dir}
+	bucket := aws.String("test-bucket")
+
+	t.Run("PutObject", func(t *testing.T) {
+		_, err := store.PutObject(&s3.PutObjectInput{
+			Body:        aws.ReadSeekCloser(bytes.NewReader([]byte(`{"name":"San Antonio"}`))),
+			Bucket:      bucket,
+			Key:         aws.String("/page/a0a0a0a0"),
+			ContentType: aws.String("application/json"),
+			Metadata:    map[string]*string{"type": aws.String("common.Page")},
+		})
+		require.Nil(t, err)
+	})
+	t.Run("GetObject", func(t *testing.T) {
+		output, err := store.GetObject(&s3.GetObjectInput{Bucket: bucket, Key: aws.String("/page/a0a0a0a0")})
+		require.Nil(t, err)
+		defer output.Body.Close()
+
+		b, err := ioutil.ReadAll(output.Body)
+		require.Nil(t, err)
+		assert.Equal(t, `{"name":"San Antonio"}`, string(b))
+		assert.Equal(t, "application/json", aws.StringValue(output.ContentType))
+		assert.Equal(t, "common.Page", aws.StringValue(output.Metadata["type"]))
+	})
+	t.Run("GetObject (not found)", func(t *testing.T) {
+		_, err := store.GetObject(&s3.GetObjectInput{Bucket: bucket, Key: aws.String("/page/bogus")})
+		var s3err awserr.Error
+		require.True(t, errors.As(err, &s3err))
+		assert.Equal(t, s3.ErrCodeNoSuchKey, s3err.Code())
+	})
+	t.Run("GetObject (invalid key)", func(t *testing.T) {
+		_, err := store.GetObject(&s3.GetObjectInput{Bucket: bucket, Key: aws.String("/../../etc/passwd")})
+		require.NotNil(t, err)
+	})
+	t.Run("DeleteObjects", func(t *testing.T) {
+		output, err := store.DeleteObjects(&s3.DeleteObjectsInput{
+			Bucket: bucket,
+			Delete: &s3.Delete{Objects: []*s3.ObjectIdentifier{{Key: aws.String("/page/a0a0a0a0")}, {Key: aws.String("/page/bogus")}}},
+		})
+		require.Nil(t, err)
+		assert.Len(t, output.Errors, 0)
+
+		_, err = store.GetObject(&s3.GetObjectInput{Bucket: bucket, Key: aws.String("/page/a0a0a0a0")})
+		require.NotNil(t, err)
+	})
+}
+
+func TestFileStoreRepository(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phoenix-store")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	repo, err := NewLocalRepository(dir, "test-bucket")
+	require.Nil(t, err)
+
+	update := &Update{
+		Page:   testPage,
+		Nodes:  []common.Node{testNode},
+		Abouts: map[string]common.Thing{"//schema.org": testAbout},
+	}
+
+	_, err = repo.Apply(update)
+	require.Nil(t, err)
+
+	page, err := repo.GetPageByName(testPage.Source.Authority, testPage.Name)
+	require.Nil(t, err)
+	assert.Equal(t, testPage.Name, page.Name)
+	require.Len(t, page.HasPart, 1)
+
+	node, err := repo.GetNodeByName(testNode.Source.Authority, page.Name, testNode.Name)
+	require.Nil(t, err)
+	assert.Equal(t, page.HasPart[0], node.ID)
+	assert.Equal(t, testNode.Unsafe, node.Unsafe)
+
+	require.Nil(t, repo.DeletePage(page.ID))
+
+	var notFound *ErrNotFound
+	_, err = repo.GetNode(node.ID)
+	assert.True(t, errors.As(err, &notFound))
+}
+
+func TestFileStoreList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phoenix-store")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	store := &FileStore{Root: dir}
+
+	t.Run("Empty bucket", func(t *testing.T) {
+		output, err := store.ListObjectsV2(&s3.ListObjectsV2Input{Bucket: aws.String("test"), Prefix: aws.String("/page/")})
+		require.Nil(t, err)
+		assert.Len(t, output.Contents, 0)
+	})
+
+	for _, key := range []string{"/page/c", "/page/a", "/page/b", "/node/a", "/history/page/a/1"} {
+		_, err := store.PutObject(&s3.PutObjectInput{Bucket: aws.String("test"), Key: aws.String(key), Body: strings.NewReader("{}")})
+		require.Nil(t, err)
+	}
+
+	t.Run("Paginated", func(t *testing.T) {
+		output, err := store.ListObjectsV2(&s3.ListObjectsV2Input{Bucket: aws.String("test"), Prefix: aws.String("/page/"), MaxKeys: aws.Int64(2)})
+		require.Nil(t, err)
+		require.Len(t, output.Contents, 2)
+		assert.Equal(t, "/page/a", *output.Contents[0].Key)
+		assert.Equal(t, "/page/b", *output.Contents[1].Key)
+		assert.True(t, *output.IsTruncated)
+
+		output, err = store.ListObjectsV2(&s3.ListObjectsV2Input{Bucket: aws.String("test"), Prefix: aws.String("/page/"), ContinuationToken: output.NextContinuationToken})
+		require.Nil(t, err)
+		require.Len(t, output.Contents, 1)
+		assert.Equal(t, "/page/c", *output.Contents[0].Key)
+		assert.False(t, *output.IsTruncated)
+	})
+
+	for _, key := range []string{"/page/a/1", "/page/a-b", "/page/a0", "/page/a/2/x"} {
+		_, err := store.PutObject(&s3.PutObjectInput{Bucket: aws.String("test"), Key: aws.String(key), Body: strings.NewReader("{}")})
+		require.Nil(t, err)
+	}
+
+	// Lists every key of a prefix, a page at a time
+	listAll := func(prefix string, limit int64) []string {
+		var keys []string
+		var token *string
+
+		for {
+			output, err := store.ListObjectsV2(&s3.ListObjectsV2Input{Bucket: aws.String("test"), Prefix: aws.String(prefix), MaxKeys: aws.Int64(limit), ContinuationToken: token})
+			require.Nil(t, err)
+
+			for _, object := range output.Contents {
+				keys = append(keys, *object.Key)
+			}
+
+			if !*output.IsTruncated {
+				return keys
+			}
+
+			token = output.NextContinuationToken
+		}
+	}
+
+	t.Run("Nested", func(t *testing.T) {
+		expected := []string{"/page/a", "/page/a-b", "/page/a/1", "/page/a/2/x", "/page/a0", "/page/b", "/page/c"}
+
+		for _, limit := range []int64{1, 2, 3, 1000} {
+			assert.Equal(t, expected, listAll("/page/", limit), "limit=%d", limit)
+		}
+	})
+	t.Run("Partial prefix", func(t *testing.T) {
+		assert.Equal(t, []string{"/page/a/1", "/page/a/2/x"}, listAll("/page/a/", 1))
+		assert.Equal(t, []string{"/page/a", "/page/a-b", "/page/a/1", "/page/a/2/x", "/page/a0"}, listAll("/page/a", 2))
+		assert.Equal(t, []string{"/history/page/a/1", "/node/a", "/page/a", "/page/a-b", "/page/a/1", "/page/a/2/x", "/page/a0", "/page/b", "/page/c"}, listAll("", 3))
+		assert.Empty(t, listAll("/pages/", 2))
+	})
+}
@@ -2,15 +2,19 @@ module github.com/wikimedia/phoenix/storage
 
 go 1.14
 
+replace github.com/wikimedia/phoenix/common => ../common
+
 require (
 	github.com/aws/aws-sdk-go v1.36.8
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/elastic/go-elasticsearch/v7 v7.10.0
 	github.com/google/uuid v1.1.2
 	github.com/kr/text v0.2.0 // indirect
+	github.com/mattn/go-sqlite3 v1.14.6
 	github.com/spaolacci/murmur3 v1.1.0
 	github.com/stretchr/testify v1.6.1
 	github.com/wikimedia/phoenix/common v0.0.0-20201207205910-f0d114bb14a4
+	go.etcd.io/bbolt v1.3.5
 	golang.org/x/net v0.0.0-20201202161906-c7110b5ffcbb // indirect
 	golang.org/x/text v0.3.4 // indirect
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
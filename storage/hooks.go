@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/wikimedia/phoenix/common"
+)
+
+// Kinds of Event
+const (
+	// A Page was published (by Apply); Delivered after the events of its Nodes, and linked-data objects.
+	EventPageStored = "page-stored"
+
+	// A Node was stored (by Apply); Unchanged Nodes are not.
+	EventNodeStored = "node-stored"
+
+	// A Node was removed, by Apply (a section removed or renamed), or by DeletePage
+	EventNodeRemoved = "node-removed"
+
+	// A linked-data object was stored (by Apply)
+	EventAboutStored = "about-stored"
+
+	// A Page was deleted (by DeletePage); Delivered after the events of its Nodes.
+	EventPageDeleted = "page-deleted"
+)
+
+// Event is a change made to the repository
+type Event struct {
+	// One of the Event* kinds
+	Kind string
+
+	// ID of the object stored (or removed)
+	ID string
+
+	// The Page stored, or deleted; For other events, the Page the object is (or was) a part of.
+	Page *common.Page
+
+	// The Node stored (nil for other events)
+	Node *common.Node
+
+	// The linked-data object stored (nil for other events)
+	About *common.Thing
+}
+
+// HookPolicy determines what becomes of an error returned by a Hook
+type HookPolicy int
+
+const (
+	// The error is returned by the Repository method that emitted the event (once the event has been delivered
+	// to the remaining subscribers).  The change itself is not undone.
+	HookFail HookPolicy = iota
+
+	// The Hook is retried (with exponential back-off); Should every attempt fail, as for HookFail.
+	HookRetry
+
+	// The error is logged (see: Hooks.Logger), and otherwise ignored.
+	HookLog
+)
+
+const (
+	// The maximum number of attempts to deliver an event to a HookRetry subscriber
+	hookMaxAttempts = 4
+)
+
+// Delay before the first retry of a HookRetry subscriber; Doubled on each subsequent attempt.
+var hookRetryDelay = 100 * time.Millisecond
+
+// Hook is a subscriber to repository events
+type Hook func(event *Event) error
+
+// Hooks is a registry of subscribers to the events of a Repository (see: Repository.Hooks).  Events are
+// delivered synchronously, in the order they occur, to subscribers in the order they subscribed.  The zero
+// value is ready to use.
+type Hooks struct {
+	// Logger for the errors of HookLog subscribers; When unset, these are discarded.
+	Logger *common.Logger
+
+	mutex       sync.RWMutex
+	subscribers []subscriber
+}
+
+type subscriber struct {
+	hook   Hook
+	policy HookPolicy
+	kinds  map[string]bool
+}
+
+// Subscribe registers hook to receive events of the given kinds (or of every kind, if none are given).
+func (h *Hooks) Subscribe(hook Hook, policy HookPolicy, kinds ...string) {
+	var s = subscriber{hook: hook, policy: policy}
+
+	if len(kinds) > 0 {
+		s.kinds = make(map[string]bool, len(kinds))
+		for _, kind := range kinds {
+			s.kinds[kind] = true
+		}
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.subscribers = append(h.subscribers, s)
+}
+
+// Delivers events (in order) to each of their subscribers; Returns the first error of a subscriber whose policy
+// is not HookLog.  A nil Hooks has no subscribers.
+func (h *Hooks) emit(events ...*Event) error {
+	var first error
+
+	if h == nil {
+		return nil
+	}
+
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	for _, event := range events {
+		for _, s := range h.subscribers {
+			if s.kinds != nil && !s.kinds[event.Kind] {
+				continue
+			}
+
+			if err := s.deliver(event); err != nil {
+				if s.policy == HookLog {
+					if h.Logger != nil {
+						h.Logger.Error("Hook failed: %s", err)
+					}
+					continue
+				}
+
+				if first == nil {
+					first = err
+				}
+			}
+		}
+	}
+
+	return first
+}
+
+// Delivers an event, retrying if the subscriber's policy is HookRetry.
+func (s subscriber) deliver(event *Event) error {
+	var delay = hookRetryDelay
+	var err error
+
+	for attempt := 1; ; attempt++ {
+		if err = s.hook(event); err == nil {
+			return nil
+		}
+
+		if s.policy != HookRetry || attempt == hookMaxAttempts {
+			return fmt.Errorf("%s event hook failed (%s): %w", event.Kind, event.ID, err)
+		}
+
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
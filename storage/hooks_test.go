@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wikimedia/phoenix/common"
+)
+
+func TestHooks(t *testing.T) {
+	var events []string
+
+	hookRetryDelay = time.Millisecond
+
+	page := testPage
+	page.Source.Revision = 60
+	page.Name = "Gruene"
+
+	history := testNode
+	history.Name = "History"
+
+	culture := testNode
+	culture.Name = "Culture"
+
+	record := func(event *Event) error {
+		events = append(events, fmt.Sprintf("%s %s", event.Kind, event.ID))
+		return nil
+	}
+
+	t.Run("Events", func(t *testing.T) {
+		repo := Repository{Store: NewMockStore(), Index: NewMockIndex(), Bucket: "test", Hooks: &Hooks{}}
+		repo.Hooks.Subscribe(record, HookFail)
+
+		events = nil
+		result, err := repo.Apply(&Update{Page: page, Nodes: []common.Node{history, culture}, Abouts: map[string]common.Thing{"//schema.org": testAbout}})
+		require.Nil(t, err)
+
+		stored, err := repo.GetPage(result.PageID)
+		require.Nil(t, err)
+
+		assert.Equal(t, []string{
+			"node-stored " + stored.HasPart[0],
+			"node-stored " + stored.HasPart[1],
+			"about-stored " + stored.About["//schema.org"],
+			"page-stored " + stored.ID,
+		}, events)
+
+		// History is unchanged, and Culture removed
+		page.Source.Revision = 61
+
+		events = nil
+		_, err = repo.Apply(&Update{Page: page, Nodes: []common.Node{history}})
+		require.Nil(t, err)
+		assert.Equal(t, []string{"page-stored " + stored.ID, "node-removed " + stored.HasPart[1]}, events)
+
+		events = nil
+		require.Nil(t, repo.DeletePage(stored.ID))
+		assert.Equal(t, []string{"node-removed " + stored.HasPart[0], "page-deleted " + stored.ID}, events)
+	})
+
+	t.Run("Kinds", func(t *testing.T) {
+		repo := Repository{Store: NewMockStore(), Index: NewMockIndex(), Bucket: "test", Hooks: &Hooks{}}
+		repo.Hooks.Subscribe(record, HookFail, EventPageStored, EventPageDeleted)
+
+		events = nil
+		result, err := repo.Apply(&Update{Page: page, Nodes: []common.Node{history, culture}})
+		require.Nil(t, err)
+		assert.Equal(t, []string{"page-stored " + result.PageID}, events)
+	})
+
+	t.Run("Policies", func(t *testing.T) {
+		var attempts int
+
+		repo := Repository{Store: NewMockStore(), Index: NewMockIndex(), Bucket: "test", Hooks: &Hooks{}}
+
+		// Fails once, then succeeds
+		repo.Hooks.Subscribe(func(event *Event) error {
+			if attempts++; attempts == 1 {
+				return errors.New("unavailable")
+			}
+			return nil
+		}, HookRetry, EventPageStored)
+
+		// Always fails (and is ignored)
+		repo.Hooks.Subscribe(func(event *Event) error {
+			return errors.New("failed")
+		}, HookLog)
+
+		repo.Hooks.Subscribe(record, HookFail)
+
+		events = nil
+		_, err := repo.Apply(&Update{Page: page, Nodes: []common.Node{history}})
+		require.Nil(t, err)
+		assert.Equal(t, 2, attempts)
+		assert.Len(t, events, 2)
+
+		// Always fails; The update is published, and the remaining subscribers notified
+		repo.Hooks.Subscribe(func(event *Event) error {
+			return errors.New("failed")
+		}, HookFail, EventNodeStored)
+
+		page.Source.Revision = 62
+		history.Unsafe = "<h1>History</h1><p>Gruene was settled in the 1840s...</p>"
+
+		events = nil
+		result, err := repo.Apply(&Update{Page: page, Nodes: []common.Node{history}})
+		require.NotNil(t, err)
+		require.NotNil(t, result)
+		assert.True(t, result.Indexed)
+		assert.Len(t, events, 2)
+
+		stored, err := repo.GetPage(result.PageID)
+		require.Nil(t, err)
+		assert.Equal(t, 62, stored.Source.Revision)
+	})
+
+	t.Run("Index failure", func(t *testing.T) {
+		repo := Repository{Store: NewMockStore(), Index: &failingIndex{MockIndex: NewMockIndex(), remaining: 1}, Bucket: "test", Hooks: &Hooks{}, Outbox: &Outbox{}}
+		repo.Hooks.Subscribe(record, HookFail)
+
+		page.Source.Revision = 63
+
+		result, err := repo.Apply(&Update{Page: page, Nodes: []common.Node{history, culture}})
+		require.Nil(t, err)
+
+		stored, err := repo.GetPage(result.PageID)
+		require.Nil(t, err)
+
+		repo.Hooks.Subscribe(func(event *Event) error {
+			return errors.New("hook failed")
+		}, HookFail, EventPageStored)
+
+		// Culture is removed, but the update fails to index; Both errors are returned.
+		page.Source.Revision = 64
+
+		events = nil
+		result, err = repo.Apply(&Update{Page: page, Nodes: []common.Node{history}})
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), "index unavailable")
+		assert.Contains(t, err.Error(), "hook failed")
+		assert.False(t, result.Indexed)
+
+		// Culture was not garbage-collected; Its removal is neither emitted, nor recorded.
+		_, err = repo.getNode(stored.HasPart[1])
+		require.Nil(t, err)
+		assert.Equal(t, []string{"page-stored " + stored.ID}, events)
+
+		keys, _, err := repo.list(outboxf(""), "", maxListKeys)
+		require.Nil(t, err)
+		for _, key := range keys {
+			event, err := repo.getOutboxEvent(key)
+			require.Nil(t, err)
+			assert.NotEqual(t, EventNodeRemoved, event.Kind)
+		}
+	})
+
+	t.Run("Linked data deletion failure", func(t *testing.T) {
+		store := &instrumentedStore{MockStore: NewMockStore()}
+		repo := Repository{Store: store, Index: NewMockIndex(), Bucket: "test", Hooks: &Hooks{}}
+		repo.Hooks.Subscribe(record, HookFail)
+
+		page.Source.Revision = 65
+
+		_, err := repo.Apply(&Update{Page: page, Nodes: []common.Node{history}, Abouts: map[string]common.Thing{"//schema.org": testAbout}})
+		require.Nil(t, err)
+
+		repo.Hooks.Subscribe(func(event *Event) error {
+			return errors.New("hook failed")
+		}, HookFail, EventPageStored)
+
+		// The update is published, but the previous revision's linked data is not deleted; The result, and both
+		// errors, are returned, and the update's events emitted.
+		page.Source.Revision = 66
+		store.deleteError = errors.New("store unavailable")
+		defer func() { store.deleteError = nil }()
+
+		events = nil
+		result, err := repo.Apply(&Update{Page: page, Nodes: []common.Node{history}, Abouts: map[string]common.Thing{"//schema.org": testAbout}})
+		require.NotNil(t, err)
+		require.NotNil(t, result)
+		assert.Contains(t, err.Error(), "store unavailable")
+		assert.Contains(t, err.Error(), "hook failed")
+		assert.Contains(t, events, "page-stored "+result.PageID)
+
+		stored, err := repo.GetPage(result.PageID)
+		require.Nil(t, err)
+		assert.Equal(t, 66, stored.Source.Revision)
+	})
+}
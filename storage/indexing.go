@@ -6,11 +6,15 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 	elasticsearch "github.com/elastic/go-elasticsearch/v7"
 	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/elastic/go-elasticsearch/v7/esutil"
 )
 
 // Index is an interface for indexing Phoenix documents
@@ -23,6 +27,22 @@ type Index interface {
 
 	// NodeIDForName queries the index for node ID matching name
 	NodeIDForName(authority, pageName, name string) (string, error)
+
+	// RemovePage removes the index entry for a page name
+	RemovePage(authority, name string) error
+
+	// RemoveNode removes the index entry for a node name
+	RemoveNode(authority, pageName, name string) error
+}
+
+// IndexScanner is implemented by Index types that can enumerate their entries (for the purposes of consistency
+// checks, and repairs).  Node names are passed as indexed (lower-cased).
+type IndexScanner interface {
+	// ScanPages invokes f for each page name entry, stopping at the first error
+	ScanPages(f func(authority, name, id string) error) error
+
+	// ScanNodes invokes f for each node name entry, stopping at the first error
+	ScanNodes(f func(authority, pageName, name, id string) error) error
 }
 
 // MockIndex is a memory-backed Index used in testing
@@ -36,10 +56,14 @@ func (i *MockIndex) Apply(update *Update) error {
 	page := update.Page
 	nodes := update.Nodes
 
+	if err := validateNodeNames(update); err != nil {
+		return err
+	}
+
 	i.pages[fmt.Sprintf("%s:%s", page.Source.Authority, page.Name)] = page.ID
 
 	for _, n := range nodes {
-		i.nodes[fmt.Sprintf("%s:%s:%s", n.Source.Authority, page.Name, n.Name)] = n.ID
+		i.nodes[fmt.Sprintf("%s:%s", n.Source.Authority, encodeNodeName(page.Name, n.Name))] = n.ID
 	}
 
 	return nil
@@ -56,94 +80,268 @@ func (i *MockIndex) PageIDForName(authority, name string) (string, error) {
 
 // NodeIDForName queries the index for node ID matching name
 func (i *MockIndex) NodeIDForName(authority, pageName, name string) (string, error) {
-	if v, ok := i.nodes[fmt.Sprintf("%s:%s:%s", authority, pageName, name)]; ok {
+	if v, ok := i.nodes[fmt.Sprintf("%s:%s", authority, encodeNodeName(pageName, name))]; ok {
 		return v, nil
 	}
 
 	return "", &ErrNotFound{fmt.Sprintf("node index: %s/%s/%s not found", authority, pageName, name)}
 }
 
+// RemovePage removes the index entry for a page name
+func (i *MockIndex) RemovePage(authority, name string) error {
+	delete(i.pages, fmt.Sprintf("%s:%s", authority, name))
+	return nil
+}
+
+// RemoveNode removes the index entry for a node name
+func (i *MockIndex) RemoveNode(authority, pageName, name string) error {
+	delete(i.nodes, fmt.Sprintf("%s:%s", authority, encodeNodeName(pageName, name)))
+	return nil
+}
+
+// ScanPages invokes f for each page name entry
+func (i *MockIndex) ScanPages(f func(authority, name, id string) error) error {
+	for key, id := range i.pages {
+		parts := strings.SplitN(key, ":", 2)
+		if err := f(parts[0], parts[1], id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ScanNodes invokes f for each node name entry
+func (i *MockIndex) ScanNodes(f func(authority, pageName, name, id string) error) error {
+	for key, id := range i.nodes {
+		parts := strings.SplitN(key, ":", 2)
+		pageName, name, err := decodeNodeName(parts[1])
+		if err != nil {
+			return err
+		}
+		if err = f(parts[0], pageName, name, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns ErrInvalid if two Nodes of an update have the same name (and so the same index key); Each would
+// overwrite the other (and a DynamoDB batch that includes duplicate keys fails with an obscure validation error).
+// Every Index rejects such an update, before writing anything.
+func validateNodeNames(update *Update) error {
+	var names = make(map[string]bool)
+
+	for _, n := range update.Nodes {
+		name := encodeNodeName(update.Page.Name, n.Name)
+		if names[name] {
+			return &ErrInvalid{message: fmt.Sprintf(`unable to index Node: name "%s" conflicts with another in this update (%+v)`, name, n)}
+		}
+		names[name] = true
+	}
+
+	return nil
+}
+
 // NewMockIndex creates a new MockIndex
 func NewMockIndex() *MockIndex {
 	return &MockIndex{make(map[string]string), make(map[string]string)}
 }
 
+// ErrPartialIndex indicates that an index update was only partially applied.  Updates are idempotent; The
+// update can be retried in its entirety.
+type ErrPartialIndex struct {
+	message string
+	err     error
+
+	// Names (page titles or encoded node names) of the entries that were not written
+	Names []string
+}
+
+func (e *ErrPartialIndex) Error() string {
+	return e.message
+}
+
+func (e *ErrPartialIndex) Unwrap() error {
+	return e.err
+}
+
+const (
+	// The maximum number of items in a DynamoDB BatchWriteItem request
+	dynamoDBMaxBatchSize = 25
+
+	// The maximum number of attempts to write the unprocessed items of a BatchWriteItem request
+	dynamoDBMaxAttempts = 8
+)
+
+// Delay before the first retry of unprocessed items; Doubled on each subsequent attempt.
+var dynamoDBRetryDelay = 50 * time.Millisecond
+
 // DynamoDBIndex is a Phoenix document indexer backed by DynamoDB
 type DynamoDBIndex struct {
-	Client      *dynamodb.DynamoDB
+	Client      dynamodbiface.DynamoDBAPI
 	TitlesTable string
 	NamesTable  string
+
+	ctx context.Context
 }
 
-// Apply updates the index with new Phoenix document data
+// WithContext returns a copy of the index whose requests are made with ctx.
+func (i *DynamoDBIndex) WithContext(ctx context.Context) Index {
+	var index = *i
+	index.ctx = ctx
+	return &index
+}
+
+// A write request, and the name it indexes (for the purposes of error reporting)
+type dynamoDBWrite struct {
+	name    string
+	table   string
+	request *dynamodb.WriteRequest
+}
+
+// Apply updates the index with new Phoenix document data.  Items are written in batches (DynamoDB limits
+// these to 25 items each), so documents of any size are indexed in full.  Should any items fail to be
+// written (after retries), an error of type ErrPartialIndex is returned.
 func (i *DynamoDBIndex) Apply(update *Update) error {
-	var count = 0
-	var items []*dynamodb.TransactWriteItem
-	var nodeNameSet = make(map[string]bool, 0)
 	var page = update.Page
+	var writes []dynamoDBWrite
 
-	items = make([]*dynamodb.TransactWriteItem, 0)
+	if err := validateNodeNames(update); err != nil {
+		return err
+	}
 
 	// Page titles
-	items = append(items, &dynamodb.TransactWriteItem{
-		Put: &dynamodb.Put{
-			Item: map[string]*dynamodb.AttributeValue{
-				"Title":     {S: aws.String(page.Name)},
-				"Authority": {S: aws.String(page.Source.Authority)},
-				"ID":        {S: aws.String(page.ID)},
+	writes = append(writes, dynamoDBWrite{
+		name:  page.Name,
+		table: i.TitlesTable,
+		request: &dynamodb.WriteRequest{
+			PutRequest: &dynamodb.PutRequest{
+				Item: map[string]*dynamodb.AttributeValue{
+					"Title":     {S: aws.String(page.Name)},
+					"Authority": {S: aws.String(page.Source.Authority)},
+					"ID":        {S: aws.String(page.ID)},
+				},
 			},
-			TableName: aws.String(i.TitlesTable),
 		},
 	})
 
-	count++
-
 	// Node names
 	for _, n := range update.Nodes {
-		// The DynamoDB transaction is limited to 25 items; Bailing out after reaching the upper bound on items means
-		// that any document with more than 24 sections (the transaction includes an item for the page as well) will
-		// have the remaining sections silently dropped. https://github.com/wikimedia/phoenix/issues/68 was opened to
-		// properly address this, but in the mean time this work-around is preferable to the resulting
-		// ValidationException.
-		if count >= 25 {
-			break
-		}
-
-		// If the resulting transaction includes two or more items with the same Name attribute, the call to
-		// TransactWriteItems that follows will fail with an (obscure) validation error.  The following is meant to
-		// detect this condition and return a more meaningful error.
 		nodeName := encodeNodeName(page.Name, n.Name)
-		if _, exists := nodeNameSet[nodeName]; exists {
-			return fmt.Errorf(`unable to index Node: name "%s" conflicts with another in this transaction (%+v)`, nodeName, n)
-		}
-		nodeNameSet[nodeName] = true
 
-		items = append(items, &dynamodb.TransactWriteItem{
-			Put: &dynamodb.Put{
-				Item: map[string]*dynamodb.AttributeValue{
-					"Name":      {S: aws.String(nodeName)},
-					"Authority": {S: aws.String(n.Source.Authority)},
-					"ID":        {S: aws.String(n.ID)},
+		writes = append(writes, dynamoDBWrite{
+			name:  nodeName,
+			table: i.NamesTable,
+			request: &dynamodb.WriteRequest{
+				PutRequest: &dynamodb.PutRequest{
+					Item: map[string]*dynamodb.AttributeValue{
+						"Name":      {S: aws.String(nodeName)},
+						"Authority": {S: aws.String(n.Source.Authority)},
+						"ID":        {S: aws.String(n.ID)},
+					},
 				},
-				TableName: aws.String(i.NamesTable),
 			},
 		})
-
-		count++
 	}
 
-	_, err := i.Client.TransactWriteItems(&dynamodb.TransactWriteItemsInput{TransactItems: items})
+	var failed []string
+	var lastErr error
 
-	if err != nil {
-		return err
+	for start := 0; start < len(writes); start += dynamoDBMaxBatchSize {
+		end := start + dynamoDBMaxBatchSize
+		if end > len(writes) {
+			end = len(writes)
+		}
+
+		names, err := i.batchWrite(writes[start:end])
+		if err != nil {
+			lastErr = err
+		}
+		failed = append(failed, names...)
+	}
+
+	if len(failed) > 0 {
+		message := fmt.Sprintf("index update of %s incomplete: %d of %d items not written", page.ID, len(failed), len(writes))
+		if lastErr != nil {
+			message = fmt.Sprintf("%s: %s", message, lastErr)
+		}
+		return &ErrPartialIndex{message: message, err: lastErr, Names: failed}
 	}
 
 	return nil
 }
 
+// Writes a batch of (at most 25) items, retrying any left unprocessed with exponential back-off.  Returns the
+// names of items that could not be written.
+func (i *DynamoDBIndex) batchWrite(writes []dynamoDBWrite) ([]string, error) {
+	var delay = dynamoDBRetryDelay
+	var pending = make(map[string][]*dynamodb.WriteRequest)
+
+	for _, w := range writes {
+		pending[w.table] = append(pending[w.table], w.request)
+	}
+
+	for attempt := 1; ; attempt++ {
+		output, err := i.Client.BatchWriteItemWithContext(contextOrBackground(i.ctx), &dynamodb.BatchWriteItemInput{RequestItems: pending})
+
+		if err != nil {
+			return unwrittenNames(writes, pending), classify(err, "batch write failed")
+		}
+
+		pending = output.UnprocessedItems
+
+		if len(pending) == 0 {
+			return nil, nil
+		}
+
+		if attempt == dynamoDBMaxAttempts {
+			return unwrittenNames(writes, pending), nil
+		}
+
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// Returns the names of writes whose requests remain in pending.  Unprocessed items are returned by DynamoDB as
+// copies, so requests are compared by value.
+func unwrittenNames(writes []dynamoDBWrite, pending map[string][]*dynamodb.WriteRequest) []string {
+	var names []string
+
+	for _, w := range writes {
+		if containsRequest(pending[w.table], w.request) {
+			names = append(names, w.name)
+		}
+	}
+
+	return names
+}
+
+func containsRequest(requests []*dynamodb.WriteRequest, request *dynamodb.WriteRequest) bool {
+	for _, r := range requests {
+		if sameItem(r.PutRequest.Item, request.PutRequest.Item) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameItem(a, b map[string]*dynamodb.AttributeValue) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if o, ok := b[k]; !ok || aws.StringValue(o.S) != aws.StringValue(v.S) {
+			return false
+		}
+	}
+	return true
+}
+
 // PageIDForName queries the index for page ID matching authority (wiki) and name
 func (i *DynamoDBIndex) PageIDForName(authority, name string) (string, error) {
-	result, err := i.Client.GetItem(
+	result, err := i.Client.GetItemWithContext(
+		contextOrBackground(i.ctx),
 		&dynamodb.GetItemInput{
 			Key: map[string]*dynamodb.AttributeValue{
 				"Title":     {S: aws.String(name)},
@@ -153,7 +351,7 @@ func (i *DynamoDBIndex) PageIDForName(authority, name string) (string, error) {
 		})
 
 	if err != nil {
-		return "", err
+		return "", classify(err, "page index query failed")
 	}
 
 	if result.Item == nil {
@@ -165,7 +363,8 @@ func (i *DynamoDBIndex) PageIDForName(authority, name string) (string, error) {
 
 // NodeIDForName queries the index for page ID matching authority (wiki) and name
 func (i *DynamoDBIndex) NodeIDForName(authority, pageName, name string) (string, error) {
-	result, err := i.Client.GetItem(
+	result, err := i.Client.GetItemWithContext(
+		contextOrBackground(i.ctx),
 		&dynamodb.GetItemInput{
 			Key: map[string]*dynamodb.AttributeValue{
 				"Name":      {S: aws.String(encodeNodeName(pageName, name))},
@@ -175,7 +374,7 @@ func (i *DynamoDBIndex) NodeIDForName(authority, pageName, name string) (string,
 		})
 
 	if err != nil {
-		return "", err
+		return "", classify(err, "node index query failed")
 	}
 
 	if result.Item == nil {
@@ -185,76 +384,295 @@ func (i *DynamoDBIndex) NodeIDForName(authority, pageName, name string) (string,
 	return *result.Item["ID"].S, nil
 }
 
+// ScanPages invokes f for each page name entry (scanning the titles table)
+func (i *DynamoDBIndex) ScanPages(f func(authority, name, id string) error) error {
+	return i.scan(i.TitlesTable, func(item map[string]*dynamodb.AttributeValue) error {
+		return f(aws.StringValue(item["Authority"].S), aws.StringValue(item["Title"].S), aws.StringValue(item["ID"].S))
+	})
+}
+
+// ScanNodes invokes f for each node name entry (scanning the names table)
+func (i *DynamoDBIndex) ScanNodes(f func(authority, pageName, name, id string) error) error {
+	return i.scan(i.NamesTable, func(item map[string]*dynamodb.AttributeValue) error {
+		pageName, name, err := decodeNodeName(aws.StringValue(item["Name"].S))
+		if err != nil {
+			return err
+		}
+		return f(aws.StringValue(item["Authority"].S), pageName, name, aws.StringValue(item["ID"].S))
+	})
+}
+
+// Invokes f for each item of a table, stopping at the first error.
+func (i *DynamoDBIndex) scan(table string, f func(map[string]*dynamodb.AttributeValue) error) error {
+	var ferr error
+
+	err := i.Client.ScanPagesWithContext(
+		contextOrBackground(i.ctx),
+		&dynamodb.ScanInput{TableName: aws.String(table)},
+		func(output *dynamodb.ScanOutput, last bool) bool {
+			for _, item := range output.Items {
+				if ferr = f(item); ferr != nil {
+					return false
+				}
+			}
+			return true
+		})
+
+	if err != nil {
+		return classify(err, fmt.Sprintf("unable to scan %s", table))
+	}
+
+	return ferr
+}
+
+// RemovePage removes the index entry for a page name
+func (i *DynamoDBIndex) RemovePage(authority, name string) error {
+	_, err := i.Client.DeleteItemWithContext(
+		contextOrBackground(i.ctx),
+		&dynamodb.DeleteItemInput{
+			Key: map[string]*dynamodb.AttributeValue{
+				"Title":     {S: aws.String(name)},
+				"Authority": {S: aws.String(authority)},
+			},
+			TableName: aws.String(i.TitlesTable),
+		})
+
+	return classify(err, fmt.Sprintf("unable to remove page name %s", name))
+}
+
+// RemoveNode removes the index entry for a node name
+func (i *DynamoDBIndex) RemoveNode(authority, pageName, name string) error {
+	_, err := i.Client.DeleteItemWithContext(
+		contextOrBackground(i.ctx),
+		&dynamodb.DeleteItemInput{
+			Key: map[string]*dynamodb.AttributeValue{
+				"Name":      {S: aws.String(encodeNodeName(pageName, name))},
+				"Authority": {S: aws.String(authority)},
+			},
+			TableName: aws.String(i.NamesTable),
+		})
+
+	return classify(err, fmt.Sprintf("unable to remove node name %s", name))
+}
+
 func encodeNodeName(pageName, name string) string {
 	return fmt.Sprintf("%s:%s", url.QueryEscape(pageName), url.QueryEscape(strings.ToLower(name)))
 }
 
+// The inverse of encodeNodeName (node names are lower-cased when encoded, and remain so).
+func decodeNodeName(encoded string) (string, string, error) {
+	var pageName, name string
+	var err error
+
+	parts := strings.Split(encoded, ":")
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("malformed node name: %s", encoded)
+	}
+
+	if pageName, err = url.QueryUnescape(parts[0]); err != nil {
+		return "", "", fmt.Errorf("malformed node name: %s: %w", encoded, err)
+	}
+
+	if name, err = url.QueryUnescape(parts[1]); err != nil {
+		return "", "", fmt.Errorf("malformed node name: %s: %w", encoded, err)
+	}
+
+	return pageName, name, nil
+}
+
+// Default Elasticsearch index names
+const (
+	defaultPageIndexName = "page_name"
+	defaultNodeIndexName = "node_name"
+)
+
 // ElasticsearchIndex is a Phoenix document indexer backed by Elasticsearch
 type ElasticsearchIndex struct {
 	Client *elasticsearch.Client
+
+	// Names of the Elasticsearch indices for page and node names; When unset, "page_name" and "node_name"
+	// are used (see: page_name_mapping.json and node_name_mapping.json).
+	PageIndex string
+	NodeIndex string
+
+	ctx context.Context
+}
+
+// WithContext returns a copy of the index whose requests are made with ctx.
+func (i *ElasticsearchIndex) WithContext(ctx context.Context) Index {
+	var index = *i
+	index.ctx = ctx
+	return &index
+}
+
+// The document type of both indices
+type nameDocument struct {
+	ID string `json:"id"`
 }
 
 // Apply updates the index with new Phoenix document data
 func (i *ElasticsearchIndex) Apply(update *Update) error {
 	page := update.Page
 
-	type document struct {
-		ID string `json:"id"`
-	}
-
 	var b []byte
 	var err error
 	var res *esapi.Response
 
-	if b, err = json.Marshal(document{ID: page.ID}); err != nil {
+	if err = validateNodeNames(update); err != nil {
+		return err
+	}
+
+	if b, err = json.Marshal(nameDocument{ID: page.ID}); err != nil {
 		return fmt.Errorf("unable to marshal json document: %w", err)
 	}
 
 	req := esapi.IndexRequest{
-		Index:      "page_name",
-		DocumentID: url.PathEscape(fmt.Sprintf("%s:%s", page.Source.Authority, page.Name)),
+		Index:      i.pageIndex(),
+		DocumentID: url.PathEscape(pageDocumentID(page.Source.Authority, page.Name)),
 		Body:       strings.NewReader(string(b)),
 		Refresh:    "true",
 	}
 
-	if res, err = req.Do(context.Background(), i.Client); err != nil {
-		return fmt.Errorf("Elasticsearch response error: %w", err)
+	if res, err = req.Do(contextOrBackground(i.ctx), i.Client); err != nil {
+		return classify(err, "Elasticsearch request failed")
 	}
 
 	defer res.Body.Close()
 
-	// FIXME: Index node names too!
-
 	if res.IsError() {
-		return fmt.Errorf("error indexing %s (status=%s)", page.Name, res.Status())
+		return classifyResponse(res, fmt.Sprintf("error indexing %s", page.Name))
 	}
 
-	return nil
+	return i.indexNodes(update)
+}
+
+// Bulk-indexes the node names of an update.  A request that fails as a whole (a 5xx response, for example) reports
+// no per-item failures, so a name counts as written only once it has been acknowledged.
+func (i *ElasticsearchIndex) indexNodes(update *Update) error {
+	var err error
+	var flushErr error
+	var failed []string
+	var indexer esutil.BulkIndexer
+	var mutex sync.Mutex
+	var page = update.Page
+	var written = make(map[string]bool)
+
+	if len(update.Nodes) == 0 {
+		return nil
+	}
+
+	config := esutil.BulkIndexerConfig{
+		Client:  i.Client,
+		Index:   i.nodeIndex(),
+		Refresh: "true",
+		OnError: func(ctx context.Context, err error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			if flushErr == nil {
+				flushErr = err
+			}
+		},
+	}
+
+	if indexer, err = esutil.NewBulkIndexer(config); err != nil {
+		return fmt.Errorf("unable to create bulk indexer: %w", err)
+	}
+
+	for _, n := range update.Nodes {
+		var data []byte
+		var name = encodeNodeName(page.Name, n.Name)
+
+		if data, err = json.Marshal(nameDocument{ID: n.ID}); err != nil {
+			return fmt.Errorf("unable to marshal json document: %w", err)
+		}
+
+		err = indexer.Add(
+			contextOrBackground(i.ctx),
+			esutil.BulkIndexerItem{
+				Action: "index",
+				// Bulk request document IDs are part of the request body (and not URL-encoded)
+				DocumentID: nodeDocumentID(n.Source.Authority, page.Name, n.Name),
+				Body:       strings.NewReader(string(data)),
+				OnSuccess: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem) {
+					mutex.Lock()
+					defer mutex.Unlock()
+					written[name] = true
+				},
+			},
+		)
+
+		if err != nil {
+			return fmt.Errorf("unable to add node %s to bulk request: %w", n.ID, err)
+		}
+	}
+
+	if err = indexer.Close(contextOrBackground(i.ctx)); err != nil {
+		return classify(err, "unexpected error encountered while closing the indexer")
+	}
+
+	for _, n := range update.Nodes {
+		if name := encodeNodeName(page.Name, n.Name); !written[name] {
+			failed = append(failed, name)
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	// A failed request is expected to succeed on retry (unlike the failures of individual items)
+	if flushErr != nil {
+		flushErr = &ErrTransient{message: fmt.Sprintf("bulk request to %s failed", i.nodeIndex()), err: flushErr}
+	}
+
+	return &ErrPartialIndex{
+		message: fmt.Sprintf("index update of %s incomplete: %d of %d node names not written", page.ID, len(failed), len(update.Nodes)),
+		err:     flushErr,
+		Names:   failed,
+	}
 }
 
 // PageIDForName queries the index for page ID matching name
 func (i *ElasticsearchIndex) PageIDForName(authority, name string) (string, error) {
+	return i.get(i.pageIndex(), pageDocumentID(authority, name), fmt.Sprintf("page index: %s/%s not found", authority, name))
+}
+
+// NodeIDForName queries the index for node ID matching name
+func (i *ElasticsearchIndex) NodeIDForName(authority, pageName, name string) (string, error) {
+	return i.get(i.nodeIndex(), nodeDocumentID(authority, pageName, name), fmt.Sprintf("node index: %s/%s/%s not found", authority, pageName, name))
+}
+
+// RemovePage removes the index entry for a page name
+func (i *ElasticsearchIndex) RemovePage(authority, name string) error {
+	return i.delete(i.pageIndex(), pageDocumentID(authority, name))
+}
+
+// RemoveNode removes the index entry for a node name
+func (i *ElasticsearchIndex) RemoveNode(authority, pageName, name string) error {
+	return i.delete(i.nodeIndex(), nodeDocumentID(authority, pageName, name))
+}
+
+// Retrieves the ID attribute of a document; Returns an ErrNotFound (with notFoundMsg) if no such document exists.
+func (i *ElasticsearchIndex) get(index, docID, notFoundMsg string) (string, error) {
 	var err error
 	var res *esapi.Response
 
-	req := esapi.GetRequest{Index: "page_name", DocumentID: fmt.Sprintf("%s:%s", authority, name)}
-	if res, err = req.Do(context.Background(), i.Client); err != nil {
-		return "", fmt.Errorf("Elasticsearch response error: %w", err)
+	req := esapi.GetRequest{Index: index, DocumentID: url.PathEscape(docID)}
+	if res, err = req.Do(contextOrBackground(i.ctx), i.Client); err != nil {
+		return "", classify(err, "Elasticsearch request failed")
 	}
 
 	defer res.Body.Close()
 
 	if res.IsError() {
 		if res.StatusCode == 404 {
-			return "", &ErrNotFound{fmt.Sprintf("page index: %s/%s not found", authority, name)}
+			return "", &ErrNotFound{notFoundMsg}
 		}
-		return "", fmt.Errorf("unknown error retrieving %s (status=%s)", name, res.Status())
+		return "", classifyResponse(res, fmt.Sprintf("error retrieving %s", docID))
 	}
 
 	type response struct {
-		Source struct {
-			ID string `json:"id"`
-		} `json:"_source"`
+		Source nameDocument `json:"_source"`
 	}
 
 	var r response
@@ -265,8 +683,44 @@ func (i *ElasticsearchIndex) PageIDForName(authority, name string) (string, erro
 	return r.Source.ID, nil
 }
 
-// NodeIDForName queries the index for page ID matching name
-func (i *ElasticsearchIndex) NodeIDForName(authority, pageName, name string) (string, error) {
-	// TODO: Do; Stubbed!
-	return "", nil
+// Removes a document; Removing a document that does not exist is not an error.
+func (i *ElasticsearchIndex) delete(index, docID string) error {
+	var err error
+	var res *esapi.Response
+
+	req := esapi.DeleteRequest{Index: index, DocumentID: url.PathEscape(docID), Refresh: "true"}
+	if res, err = req.Do(contextOrBackground(i.ctx), i.Client); err != nil {
+		return classify(err, "Elasticsearch request failed")
+	}
+
+	defer res.Body.Close()
+
+	if res.IsError() && res.StatusCode != 404 {
+		return classifyResponse(res, fmt.Sprintf("error removing %s", docID))
+	}
+
+	return nil
+}
+
+func (i *ElasticsearchIndex) pageIndex() string {
+	if i.PageIndex != "" {
+		return i.PageIndex
+	}
+	return defaultPageIndexName
+}
+
+func (i *ElasticsearchIndex) nodeIndex() string {
+	if i.NodeIndex != "" {
+		return i.NodeIndex
+	}
+	return defaultNodeIndexName
+}
+
+// Document IDs; Node names are encoded using the same semantics as other Index implementations.
+func pageDocumentID(authority, name string) string {
+	return fmt.Sprintf("%s:%s", authority, name)
+}
+
+func nodeDocumentID(authority, pageName, name string) string {
+	return fmt.Sprintf("%s:%s", authority, encodeNodeName(pageName, name))
 }
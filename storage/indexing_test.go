@@ -1,15 +1,77 @@
 package storage
 
 import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	elasticsearch "github.com/elastic/go-elasticsearch/v7"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/wikimedia/phoenix/common"
+	bolt "go.etcd.io/bbolt"
 )
 
 func TestIndex(t *testing.T) {
-	index := GetTestIndex()
+	testIndex(t, GetTestIndex())
+}
+
+func TestBoltIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phoenix-index")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	index, err := NewBoltIndex(filepath.Join(dir, "index.db"))
+	require.Nil(t, err)
+
+	testIndex(t, index)
+
+	t.Run("Shared", func(t *testing.T) {
+		other, err := NewBoltIndex(filepath.Join(dir, "index.db"))
+		require.Nil(t, err)
+
+		require.Nil(t, other.Apply(&Update{Page: common.Page{ID: "/page/b0b0b0b0b0b0b", Name: "Kyle", Source: common.Source{Authority: "fake.wikipedia.org"}}}))
+
+		id, err := index.PageIDForName("fake.wikipedia.org", "Kyle")
+		require.Nil(t, err)
+		assert.Equal(t, "/page/b0b0b0b0b0b0b", id)
+	})
+
+	t.Run("Locked", func(t *testing.T) {
+		timeout := boltLockTimeout
+		boltLockTimeout = 50 * time.Millisecond
+		defer func() { boltLockTimeout = timeout }()
+
+		// Held open (for writing) by another process, for example
+		db, err := bolt.Open(filepath.Join(dir, "index.db"), 0644, nil)
+		require.Nil(t, err)
+		defer db.Close()
+
+		_, err = index.PageIDForName("fake.wikipedia.org", "Kyle")
+		require.NotNil(t, err)
+
+		var conflict *ErrConflict
+		assert.True(t, errors.As(err, &conflict))
+	})
+}
+
+// Exercises an Index implementation
+func testIndex(t *testing.T, index Index) {
 	source := common.Source{Authority: "fake.wikipedia.org"}
 
 	err := index.Apply(
@@ -46,4 +108,249 @@ func TestIndex(t *testing.T) {
 	require.NotNil(t, err)
 	_, ok = err.(*ErrNotFound)
 	require.True(t, ok, "Expected an error of type ErrNotFound")
+
+	// Nodes whose names (and so index keys) conflict are rejected, and nothing is written
+	err = index.Apply(
+		&Update{
+			Page: common.Page{ID: "/page/a0a0a0a0a0a0a", Name: "San Marcos", Source: source},
+			Nodes: []common.Node{
+				{ID: "/node/b0b0b0b0b0b0b", Source: source, Name: "Geography"},
+				{ID: "/node/c0c0c0c0c0c0c", Source: source, Name: "geography"},
+			},
+		})
+	require.NotNil(t, err)
+	_, ok = err.(*ErrInvalid)
+	require.True(t, ok, "Expected an error of type ErrInvalid")
+
+	_, err = index.NodeIDForName("fake.wikipedia.org", "San Marcos", "Geography")
+	_, ok = err.(*ErrNotFound)
+	require.True(t, ok, "Expected an error of type ErrNotFound")
+
+	if scanner, ok := index.(IndexScanner); ok {
+		var pages, nodes []string
+
+		require.Nil(t, scanner.ScanPages(func(authority, name, id string) error {
+			pages = append(pages, fmt.Sprintf("%s/%s=%s", authority, name, id))
+			return nil
+		}))
+		assert.Contains(t, pages, "fake.wikipedia.org/San Marcos=/page/a0a0a0a0a0a0a")
+
+		require.Nil(t, scanner.ScanNodes(func(authority, pageName, name, id string) error {
+			nodes = append(nodes, fmt.Sprintf("%s/%s/%s=%s", authority, pageName, name, id))
+			return nil
+		}))
+		assert.Contains(t, nodes, "fake.wikipedia.org/San Marcos/history=/node/a0a0a0a0a0a0a")
+	}
+
+	require.Nil(t, index.RemoveNode("fake.wikipedia.org", "San Marcos", "History"))
+	_, err = index.NodeIDForName("fake.wikipedia.org", "San Marcos", "History")
+	_, ok = err.(*ErrNotFound)
+	require.True(t, ok, "Expected an error of type ErrNotFound")
+
+	require.Nil(t, index.RemovePage("fake.wikipedia.org", "San Marcos"))
+	_, err = index.PageIDForName("fake.wikipedia.org", "San Marcos")
+	_, ok = err.(*ErrNotFound)
+	require.True(t, ok, "Expected an error of type ErrNotFound")
+}
+
+// A fake DynamoDB client that leaves a configurable number of items unprocessed by the first (failing) calls
+// to BatchWriteItem
+type fakeDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+	items       map[string]string
+	failing     int
+	unprocessed int
+}
+
+func (f *fakeDynamoDB) BatchWriteItemWithContext(ctx aws.Context, input *dynamodb.BatchWriteItemInput, opts ...request.Option) (*dynamodb.BatchWriteItemOutput, error) {
+	var count int
+	var output = &dynamodb.BatchWriteItemOutput{UnprocessedItems: make(map[string][]*dynamodb.WriteRequest)}
+
+	for table, requests := range input.RequestItems {
+		if len(requests) > 25 {
+			return nil, fmt.Errorf("too many items in batch (%d)", len(requests))
+		}
+
+		for _, r := range requests {
+			if f.failing > 0 && count < f.unprocessed {
+				output.UnprocessedItems[table] = append(output.UnprocessedItems[table], r)
+				count++
+				continue
+			}
+
+			item := r.PutRequest.Item
+			if name, ok := item["Name"]; ok {
+				f.items[*name.S] = *item["ID"].S
+			} else {
+				f.items[*item["Title"].S] = *item["ID"].S
+			}
+		}
+	}
+
+	f.failing--
+
+	return output, nil
+}
+
+func TestDynamoDBIndexBatching(t *testing.T) {
+	var nodes []common.Node
+	var source = common.Source{Authority: "fake.wikipedia.org"}
+
+	dynamoDBRetryDelay = time.Millisecond
+
+	for i := 0; i < 60; i++ {
+		nodes = append(nodes, common.Node{ID: fmt.Sprintf("/node/%d", i), Source: source, Name: fmt.Sprintf("Section %d", i)})
+	}
+
+	update := &Update{Page: common.Page{ID: "/page/a0a0a0a0a0a0a", Name: "Austin", Source: source}, Nodes: nodes}
+
+	t.Run("All items written", func(t *testing.T) {
+		client := &fakeDynamoDB{items: make(map[string]string), failing: 2, unprocessed: 5}
+		index := &DynamoDBIndex{Client: client, TitlesTable: "titles", NamesTable: "names"}
+
+		require.Nil(t, index.Apply(update))
+		assert.Len(t, client.items, 61)
+		assert.Equal(t, "/node/59", client.items[encodeNodeName("Austin", "Section 59")])
+	})
+	t.Run("Partial failure", func(t *testing.T) {
+		client := &fakeDynamoDB{items: make(map[string]string), failing: 1000, unprocessed: 25}
+		index := &DynamoDBIndex{Client: client, TitlesTable: "titles", NamesTable: "names"}
+
+		err := index.Apply(update)
+		var partial *ErrPartialIndex
+		require.True(t, errors.As(err, &partial), "Expected an error of type ErrPartialIndex")
+		assert.Len(t, partial.Names, 61)
+	})
+}
+
+// A fake Elasticsearch server, implementing just enough of the document and bulk APIs for ElasticsearchIndex
+type fakeElasticsearch struct {
+	mutex sync.Mutex
+	docs  map[string]json.RawMessage
+
+	// When set, bulk requests fail with a 500 response
+	failBulk bool
+}
+
+func (f *fakeElasticsearch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var segments = strings.Split(strings.TrimPrefix(r.URL.EscapedPath(), "/"), "/")
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	// Bulk requests (/{index}/_bulk)
+	if len(segments) == 2 && segments[1] == "_bulk" {
+		if f.failBulk {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"error":{"type":"internal_server_error"},"status":500}`)
+			return
+		}
+
+		var items []map[string]interface{}
+		var scanner = bufio.NewScanner(r.Body)
+
+		for scanner.Scan() {
+			var action map[string]struct {
+				ID string `json:"_id"`
+			}
+
+			if err := json.Unmarshal(scanner.Bytes(), &action); err != nil || !scanner.Scan() {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			f.docs[segments[0]+"/"+action["index"].ID] = append(json.RawMessage{}, scanner.Bytes()...)
+			items = append(items, map[string]interface{}{"index": map[string]interface{}{"_id": action["index"].ID, "status": 201}})
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{"errors": false, "items": items})
+		return
+	}
+
+	// Document requests (/{index}/_doc/{id})
+	if len(segments) != 3 || segments[1] != "_doc" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	id, _ := url.PathUnescape(segments[2])
+	key := segments[0] + "/" + id
+
+	switch r.Method {
+	case http.MethodPut, http.MethodPost:
+		body, _ := ioutil.ReadAll(r.Body)
+		f.docs[key] = body
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"_id":%q,"result":"created"}`, id)
+	case http.MethodGet:
+		if doc, ok := f.docs[key]; ok {
+			fmt.Fprintf(w, `{"_id":%q,"found":true,"_source":%s}`, id, doc)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"_id":%q,"found":false}`, id)
+	case http.MethodDelete:
+		if _, ok := f.docs[key]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.docs, key)
+		fmt.Fprintf(w, `{"_id":%q,"result":"deleted"}`, id)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestElasticsearchIndex(t *testing.T) {
+	fake := &fakeElasticsearch{docs: make(map[string]json.RawMessage)}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{server.URL}})
+	require.Nil(t, err)
+
+	t.Run("Default index names", func(t *testing.T) {
+		testIndex(t, &ElasticsearchIndex{Client: client})
+	})
+
+	t.Run("Configured index names", func(t *testing.T) {
+		index := &ElasticsearchIndex{Client: client, PageIndex: "test_page_name", NodeIndex: "test_node_name"}
+		source := common.Source{Authority: "fake.wikipedia.org"}
+
+		err := index.Apply(
+			&Update{
+				Page:  common.Page{ID: "/page/b0b0b0b0b0b0b", Name: "AC/DC", Source: source},
+				Nodes: []common.Node{{ID: "/node/b0b0b0b0b0b0b", Name: "Band members", Source: source}},
+			})
+		require.Nil(t, err)
+
+		assert.Contains(t, fake.docs, "test_page_name/fake.wikipedia.org:AC/DC")
+		assert.Contains(t, fake.docs, "test_node_name/"+nodeDocumentID("fake.wikipedia.org", "AC/DC", "Band members"))
+
+		id, err := index.NodeIDForName("fake.wikipedia.org", "AC/DC", "Band members")
+		require.Nil(t, err)
+		assert.Equal(t, "/node/b0b0b0b0b0b0b", id)
+	})
+
+	t.Run("Bulk failure", func(t *testing.T) {
+		fake.failBulk = true
+		defer func() { fake.failBulk = false }()
+
+		source := common.Source{Authority: "fake.wikipedia.org"}
+		err := (&ElasticsearchIndex{Client: client}).Apply(
+			&Update{
+				Page:  common.Page{ID: "/page/c0c0c0c0c0c0c", Name: "Black Sabbath", Source: source},
+				Nodes: []common.Node{{ID: "/node/c0c0c0c0c0c0c", Name: "History", Source: source}},
+			})
+		require.NotNil(t, err)
+
+		var perr *ErrPartialIndex
+		require.True(t, errors.As(err, &perr))
+		assert.Equal(t, []string{encodeNodeName("Black Sabbath", "History")}, perr.Names)
+
+		var terr *ErrTransient
+		assert.True(t, errors.As(err, &terr))
+	})
 }
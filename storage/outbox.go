@@ -0,0 +1,430 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+	"github.com/wikimedia/phoenix/common"
+)
+
+// Outbox configures the recording of repository events for delivery by a Relay (see: Repository.Outbox).
+// Events are recorded (as objects of the repository's bucket) ahead of the writes they describe, so that they
+// survive a failure to complete them.  Those of an Apply are recorded as pending, and are only released to the
+// Relay once the update is published (the pending events of an update that was interrupted are settled by the
+// Relay).  Delivery is at-least-once; Consumers should act on the current state of the objects events refer to.
+type Outbox struct {
+	// Kinds of event recorded (see: Event); When empty, events of every kind are.
+	Kinds []string
+}
+
+// OutboxEvent is an event recorded to the outbox
+type OutboxEvent struct {
+	// One of the Event* kinds
+	Kind string `json:"kind"`
+
+	// ID of the object stored (or removed), and of the Page it is (or was) a part of
+	ID     string `json:"id"`
+	PageID string `json:"pageId"`
+
+	// Revision of the Page the event was recorded for
+	Revision int `json:"revision,omitempty"`
+
+	Created time.Time `json:"created"`
+
+	// Failed attempts to deliver the event
+	Attempts int `json:"attempts"`
+
+	// Key of the outbox entry
+	key string
+}
+
+// Returns true if events of kind are recorded
+func (o *Outbox) records(kind string) bool {
+	if o == nil {
+		return false
+	}
+
+	if len(o.Kinds) == 0 {
+		return true
+	}
+
+	for _, k := range o.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Records events to the outbox (if any), and returns the entries recorded.  When stage is set, entries are
+// recorded as pending (passing the key of each to stage before it is written), and are not delivered until
+// released (see: release).
+func (r *Repository) record(events []*Event, stage func(key string)) ([]*OutboxEvent, error) {
+	var entries []*OutboxEvent
+	var created = time.Now().UTC()
+	var keyf = outboxf
+	var err error
+
+	if stage != nil {
+		keyf = outboxPendingf
+	}
+
+	for _, event := range events {
+		if !r.Outbox.records(event.Kind) {
+			continue
+		}
+
+		// Keys sort in the order events are recorded
+		entry := &OutboxEvent{Kind: event.Kind, ID: event.ID, Created: created}
+		entry.key = keyf(fmt.Sprintf("%020d-%04d-%s", created.UnixNano(), len(entries), makeRandomID()))
+
+		if event.Page != nil {
+			entry.PageID = event.Page.ID
+			entry.Revision = event.Page.Source.Revision
+		}
+
+		entries = append(entries, entry)
+	}
+
+	err = forEach(len(entries), r.concurrency(), func(i int) error {
+		if stage != nil {
+			stage(entries[i].key)
+		}
+
+		if err := r.putOutboxEvent(entries[i].key, entries[i]); err != nil {
+			return fmt.Errorf("error recording %s event (%s): %w", entries[i].Kind, entries[i].ID, err)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// Moves pending entries (see: record) to the outbox, once the update they were recorded for is published.
+// Entries keep their names, and so their place in the order of delivery.
+func (r *Repository) release(entries []*OutboxEvent) error {
+	return forEach(len(entries), r.concurrency(), func(i int) error {
+		var pending = entries[i].key
+		var key = outboxf(pending[len(outboxPendingf("")):])
+
+		if err := r.putOutboxEvent(key, entries[i]); err != nil {
+			return fmt.Errorf("error releasing %s event (%s): %w", entries[i].Kind, entries[i].ID, err)
+		}
+
+		if err := r.delete([]string{pending}); err != nil {
+			return fmt.Errorf("error releasing %s event (%s): %w", entries[i].Kind, entries[i].ID, err)
+		}
+
+		entries[i].key = key
+
+		return nil
+	})
+}
+
+func (r *Repository) getOutboxEvent(key string) (*OutboxEvent, error) {
+	var data *json.Decoder
+	var event = &OutboxEvent{key: key}
+	var err error
+
+	if data, err = r.get(key); err != nil {
+		return nil, err
+	}
+
+	if err = data.Decode(event); err != nil {
+		return nil, fmt.Errorf("Unable to deserialize JSON: %w", err)
+	}
+
+	return event, nil
+}
+
+func (r *Repository) putOutboxEvent(key string, event *OutboxEvent) error {
+	var data []byte
+	var err error
+
+	if data, err = encodeJSON(event); err != nil {
+		return err
+	}
+
+	return r.put(key, data, map[string]*string{"type": aws.String("storage.OutboxEvent")})
+}
+
+// Publisher delivers the events of an outbox (see: Relay)
+type Publisher interface {
+	Publish(event *OutboxEvent) error
+}
+
+// SNSPublisher publishes events to SNS topics, by kind, as JSON objects with the ID of the object the event
+// refers to (see: common.NodeStoredEvent, and common.PageStoredEvent).  Events of any other kind are dropped.
+type SNSPublisher struct {
+	Client snsiface.SNSAPI
+
+	// Topic ARNs, by event kind
+	Topics map[string]string
+}
+
+// Publish publishes an event to the topic of its kind
+func (p *SNSPublisher) Publish(event *OutboxEvent) error {
+	var b []byte
+	var err error
+	var topic string
+	var ok bool
+
+	if topic, ok = p.Topics[event.Kind]; !ok {
+		return nil
+	}
+
+	if b, err = json.Marshal(struct {
+		ID string `json:"id"`
+	}{event.ID}); err != nil {
+		return fmt.Errorf("unable to marshal SNS event to JSON: %w", err)
+	}
+
+	_, err = p.Client.Publish(&sns.PublishInput{Message: aws.String(string(b)), TopicArn: aws.String(topic)})
+
+	return classify(err, fmt.Sprintf("unable to publish %s event (%s)", event.Kind, event.ID))
+}
+
+// FilePublisher appends events to a file (as newline-delimited JSON); It is a local stand-in for a message
+// queue, for use in development and testing.
+type FilePublisher struct {
+	Path string
+
+	mutex sync.Mutex
+}
+
+// Publish appends an event to the file
+func (p *FilePublisher) Publish(event *OutboxEvent) error {
+	var b []byte
+	var f *os.File
+	var err error
+
+	if b, err = json.Marshal(event); err != nil {
+		return err
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if f, err = os.OpenFile(p.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); err != nil {
+		return err
+	}
+
+	if _, err = f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+const (
+	// The number of attempts to publish an event made by a single Relay run
+	relayMaxAttempts = 3
+
+	// The default number of failed attempts (across runs) after which an event is set aside
+	defaultMaxDeliveries = 10
+)
+
+var (
+	// Delay before the first retry of a Publish; Doubled on each subsequent attempt.
+	relayRetryDelay = 100 * time.Millisecond
+
+	// Age after which a pending entry is assumed to belong to an interrupted update (see: Relay)
+	outboxPendingTimeout = 10 * time.Minute
+)
+
+// Relay delivers the events recorded to the outbox of a Repository, oldest first.  Events are removed once
+// they are published; Those that fail to be (after retries) are left for a subsequent run, and once they have
+// failed MaxAttempts times, are set aside (under /outbox-failed/) for inspection.  Concurrent runs can publish
+// the same event more than once.
+//
+// Pending events (those of an Apply not yet published) are skipped, until they are older than an update could
+// be; These belong to an interrupted update, and are released if the Page's current revision is the one they
+// were recorded for, or discarded otherwise.
+type Relay struct {
+	Repository *Repository
+	Publisher  Publisher
+
+	// Failed attempts after which an event is set aside; When zero (or less), 10.
+	MaxAttempts int
+}
+
+// RelayStats reports the outcome of a Relay run
+type RelayStats struct {
+	// Events published
+	Delivered int `json:"delivered"`
+
+	// Events that could not be published (including those set aside)
+	Failed int `json:"failed"`
+
+	// Events set aside (having failed MaxAttempts times)
+	SetAside int `json:"setAside"`
+
+	// Pending events of interrupted updates that were discarded (those of published updates are released, and
+	// delivered)
+	Discarded int `json:"discarded"`
+}
+
+// Run makes a single pass over the outbox (once pending events are settled), publishing each of the events
+// recorded to it.  Errors of the Publisher are counted (see: RelayStats), not returned.
+func (r *Relay) Run() (*RelayStats, error) {
+	var stats = &RelayStats{}
+	var cursor string
+	var err error
+
+	if err = r.settle(stats); err != nil {
+		return stats, err
+	}
+
+	for {
+		var keys []string
+		var more bool
+
+		if keys, more, err = r.Repository.list(outboxf(""), cursor, maxListKeys); err != nil {
+			return stats, fmt.Errorf("error listing outbox: %w", err)
+		}
+
+		for _, key := range keys {
+			if err = r.relay(key, stats); err != nil {
+				return stats, err
+			}
+			cursor = key
+		}
+
+		if !more || len(keys) == 0 {
+			return stats, nil
+		}
+	}
+}
+
+// Releases (or discards) the pending events of interrupted updates
+func (r *Relay) settle(stats *RelayStats) error {
+	var cursor string
+	var expired = time.Now().Add(-outboxPendingTimeout)
+
+	for {
+		var keys []string
+		var more bool
+		var err error
+
+		if keys, more, err = r.Repository.list(outboxPendingf(""), cursor, maxListKeys); err != nil {
+			return fmt.Errorf("error listing pending outbox: %w", err)
+		}
+
+		for _, key := range keys {
+			var event *OutboxEvent
+			var page *common.Page
+
+			cursor = key
+
+			if event, err = r.Repository.getOutboxEvent(key); err != nil {
+				// Released (or settled by a concurrent run)
+				if isNotFound(err) {
+					continue
+				}
+				return fmt.Errorf("error reading %s: %w", key, err)
+			}
+
+			// Possibly that of an update still in progress
+			if event.Created.After(expired) {
+				continue
+			}
+
+			if page, err = r.Repository.GetPage(event.PageID); err != nil && !isNotFound(err) {
+				return err
+			}
+
+			if page != nil && page.Source.Revision == event.Revision {
+				if err = r.Repository.release([]*OutboxEvent{event}); err != nil {
+					return err
+				}
+				continue
+			}
+
+			stats.Discarded++
+
+			if err = r.Repository.delete([]string{key}); err != nil {
+				return err
+			}
+		}
+
+		if !more || len(keys) == 0 {
+			return nil
+		}
+	}
+}
+
+// Publishes the event recorded under key
+func (r *Relay) relay(key string, stats *RelayStats) error {
+	var delay = relayRetryDelay
+	var event *OutboxEvent
+	var err error
+
+	if event, err = r.Repository.getOutboxEvent(key); err != nil {
+		// Published by a concurrent run
+		if isNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("error reading %s: %w", key, err)
+	}
+
+	for attempt := 1; ; attempt++ {
+		if err = r.Publisher.Publish(event); err == nil {
+			stats.Delivered++
+			return r.Repository.delete([]string{key})
+		}
+
+		if attempt == relayMaxAttempts {
+			break
+		}
+
+		time.Sleep(delay)
+		delay *= 2
+	}
+
+	stats.Failed++
+	event.Attempts++
+
+	if event.Attempts < r.maxAttempts() {
+		return r.Repository.putOutboxEvent(key, event)
+	}
+
+	stats.SetAside++
+
+	if err = r.Repository.putOutboxEvent(outboxFailedf(key[len(outboxf("")):]), event); err != nil {
+		return err
+	}
+
+	return r.Repository.delete([]string{key})
+}
+
+func (r *Relay) maxAttempts() int {
+	if r.MaxAttempts > 0 {
+		return r.MaxAttempts
+	}
+	return defaultMaxDeliveries
+}
+
+func outboxf(id string) string {
+	return fmt.Sprintf("/outbox/%s", id)
+}
+
+func outboxPendingf(id string) string {
+	return fmt.Sprintf("/outbox-pending/%s", id)
+}
+
+func outboxFailedf(id string) string {
+	return fmt.Sprintf("/outbox-failed/%s", id)
+}
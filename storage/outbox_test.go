@@ -0,0 +1,299 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wikimedia/phoenix/common"
+)
+
+// A Publisher that records the events it is passed, failing while failing is non-zero
+type recordingPublisher struct {
+	events  []string
+	failing int
+
+	// If set, called with each event published
+	verify func(event *OutboxEvent)
+
+	mutex sync.Mutex
+}
+
+func (p *recordingPublisher) Publish(event *OutboxEvent) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.failing != 0 {
+		p.failing--
+		return errors.New("unable to publish")
+	}
+
+	if p.verify != nil {
+		p.verify(event)
+	}
+
+	p.events = append(p.events, event.Kind+" "+event.ID)
+	return nil
+}
+
+func TestOutbox(t *testing.T) {
+	relayRetryDelay = time.Millisecond
+
+	page := testPage
+	page.Source.Revision = 70
+	page.Name = "Wimberley"
+
+	history := testNode
+	history.Name = "History"
+
+	culture := testNode
+	culture.Name = "Culture"
+
+	t.Run("Relay", func(t *testing.T) {
+		repo := &Repository{Store: NewMockStore(), Index: NewMockIndex(), Bucket: "test", Outbox: &Outbox{}}
+		publisher := &recordingPublisher{}
+		relay := &Relay{Repository: repo, Publisher: publisher}
+
+		result, err := repo.Apply(&Update{Page: page, Nodes: []common.Node{history, culture}})
+		require.Nil(t, err)
+
+		stored, err := repo.GetPage(result.PageID)
+		require.Nil(t, err)
+
+		// Culture is removed
+		page.Source.Revision = 71
+		_, err = repo.Apply(&Update{Page: page, Nodes: []common.Node{history}})
+		require.Nil(t, err)
+
+		stats, err := relay.Run()
+		require.Nil(t, err)
+		assert.Equal(t, &RelayStats{Delivered: 5}, stats)
+		assert.Equal(t, []string{
+			"node-stored " + stored.HasPart[0],
+			"node-stored " + stored.HasPart[1],
+			"page-stored " + stored.ID,
+			"page-stored " + stored.ID,
+			"node-removed " + stored.HasPart[1],
+		}, publisher.events)
+
+		// Delivered events are removed
+		publisher.events = nil
+		stats, err = relay.Run()
+		require.Nil(t, err)
+		assert.Equal(t, &RelayStats{}, stats)
+		assert.Empty(t, publisher.events)
+
+		require.Nil(t, repo.DeletePage(stored.ID))
+		_, err = relay.Run()
+		require.Nil(t, err)
+		assert.Equal(t, []string{"node-removed " + stored.HasPart[0], "page-deleted " + stored.ID}, publisher.events)
+	})
+
+	t.Run("Kinds", func(t *testing.T) {
+		repo := &Repository{Store: NewMockStore(), Index: NewMockIndex(), Bucket: "test", Outbox: &Outbox{Kinds: []string{EventPageStored}}}
+		publisher := &recordingPublisher{}
+
+		result, err := repo.Apply(&Update{Page: page, Nodes: []common.Node{history, culture}})
+		require.Nil(t, err)
+
+		_, err = (&Relay{Repository: repo, Publisher: publisher}).Run()
+		require.Nil(t, err)
+		assert.Equal(t, []string{"page-stored " + result.PageID}, publisher.events)
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		store := &instrumentedStore{MockStore: NewMockStore(), failPrefix: revisionsf(pagef(""))}
+		repo := &Repository{Store: store, Index: NewMockIndex(), Bucket: "test", Outbox: &Outbox{}}
+
+		_, err := repo.Apply(&Update{Page: page, Nodes: []common.Node{history}})
+		require.NotNil(t, err)
+
+		// The events of an update that was rolled back are never delivered
+		keys, _, err := repo.list(outboxf(""), "", maxListKeys)
+		require.Nil(t, err)
+		assert.Empty(t, keys)
+
+		keys, _, err = repo.list(outboxPendingf(""), "", maxListKeys)
+		require.Nil(t, err)
+		assert.Empty(t, keys)
+	})
+
+	t.Run("Pending", func(t *testing.T) {
+		repo := &Repository{Store: NewMockStore(), Index: NewMockIndex(), Bucket: "test", Outbox: &Outbox{Kinds: []string{EventPageStored}}}
+		publisher := &recordingPublisher{}
+		relay := &Relay{Repository: repo, Publisher: publisher}
+		stage := func(key string) {}
+
+		page.Source.Revision = 72
+
+		result, err := repo.Apply(&Update{Page: page, Nodes: []common.Node{history}})
+		require.Nil(t, err)
+
+		stored, err := repo.GetPage(result.PageID)
+		require.Nil(t, err)
+
+		// Pending events of interrupted updates; One of the published revision, and one of a revision never
+		// published.
+		published := *stored
+		unpublished := *stored
+		unpublished.Source.Revision = 73
+
+		_, err = repo.record([]*Event{{Kind: EventPageStored, ID: stored.ID, Page: &published}}, stage)
+		require.Nil(t, err)
+		_, err = repo.record([]*Event{{Kind: EventPageStored, ID: stored.ID, Page: &unpublished}}, stage)
+		require.Nil(t, err)
+
+		// Skipped, while the updates could still be in progress
+		stats, err := relay.Run()
+		require.Nil(t, err)
+		assert.Equal(t, &RelayStats{Delivered: 1}, stats)
+
+		keys, _, err := repo.list(outboxPendingf(""), "", maxListKeys)
+		require.Nil(t, err)
+		assert.Len(t, keys, 2)
+
+		defer func(timeout time.Duration) { outboxPendingTimeout = timeout }(outboxPendingTimeout)
+		outboxPendingTimeout = 0
+
+		stats, err = relay.Run()
+		require.Nil(t, err)
+		assert.Equal(t, &RelayStats{Delivered: 1, Discarded: 1}, stats)
+		assert.Equal(t, []string{"page-stored " + stored.ID, "page-stored " + stored.ID}, publisher.events)
+
+		keys, _, err = repo.list(outboxPendingf(""), "", maxListKeys)
+		require.Nil(t, err)
+		assert.Empty(t, keys)
+	})
+
+	t.Run("Concurrent relays", func(t *testing.T) {
+		var wg sync.WaitGroup
+		var pages []string
+
+		repo := &Repository{Store: NewMockStore(), Index: NewMockIndex(), Bucket: "test", Outbox: &Outbox{Kinds: []string{EventPageStored}}}
+		publisher := &recordingPublisher{}
+		publisher.verify = func(event *OutboxEvent) {
+			_, err := repo.GetPage(event.ID)
+			assert.Nil(t, err, "%s delivered before it was published", event.ID)
+		}
+
+		// Relays run while pages are being stored; None delivers the events of an update before it is published.
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 10; j++ {
+					_, err := (&Relay{Repository: repo, Publisher: publisher}).Run()
+					assert.Nil(t, err)
+				}
+			}()
+		}
+
+		for i := 0; i < 20; i++ {
+			next := page
+			next.Source.ID = 200 + i
+			next.Name = fmt.Sprintf("Page %d", i)
+
+			result, err := repo.Apply(&Update{Page: next, Nodes: []common.Node{history}})
+			require.Nil(t, err)
+			pages = append(pages, "page-stored "+result.PageID)
+		}
+
+		wg.Wait()
+
+		// What remains is delivered by a subsequent run
+		_, err := (&Relay{Repository: repo, Publisher: publisher}).Run()
+		require.Nil(t, err)
+
+		// At-least-once; Each event is delivered, some possibly more than once.
+		assert.Subset(t, publisher.events, pages)
+		for _, event := range publisher.events {
+			assert.Contains(t, pages, event)
+		}
+
+		keys, _, err := repo.list(outboxf(""), "", maxListKeys)
+		require.Nil(t, err)
+		assert.Empty(t, keys)
+
+		keys, _, err = repo.list(outboxPendingf(""), "", maxListKeys)
+		require.Nil(t, err)
+		assert.Empty(t, keys)
+	})
+
+	t.Run("Retries", func(t *testing.T) {
+		repo := &Repository{Store: NewMockStore(), Index: NewMockIndex(), Bucket: "test", Outbox: &Outbox{Kinds: []string{EventPageStored}}}
+		publisher := &recordingPublisher{failing: relayMaxAttempts}
+		relay := &Relay{Repository: repo, Publisher: publisher, MaxAttempts: 2}
+
+		result, err := repo.Apply(&Update{Page: page, Nodes: []common.Node{history}})
+		require.Nil(t, err)
+
+		// Fails (every attempt of) the first run, and succeeds on the first attempt of the next
+		stats, err := relay.Run()
+		require.Nil(t, err)
+		assert.Equal(t, &RelayStats{Failed: 1}, stats)
+
+		stats, err = relay.Run()
+		require.Nil(t, err)
+		assert.Equal(t, &RelayStats{Delivered: 1}, stats)
+		assert.Equal(t, []string{"page-stored " + result.PageID}, publisher.events)
+
+		// Set aside after MaxAttempts failed runs
+		publisher.failing = -1
+		_, err = repo.Apply(&Update{Page: page, Nodes: []common.Node{history}, Force: true})
+		require.Nil(t, err)
+
+		_, err = relay.Run()
+		require.Nil(t, err)
+		stats, err = relay.Run()
+		require.Nil(t, err)
+		assert.Equal(t, &RelayStats{Failed: 1, SetAside: 1}, stats)
+
+		keys, _, err := repo.list(outboxf(""), "", maxListKeys)
+		require.Nil(t, err)
+		assert.Empty(t, keys)
+
+		keys, _, err = repo.list(outboxFailedf(""), "", maxListKeys)
+		require.Nil(t, err)
+		require.Len(t, keys, 1)
+
+		event, err := repo.getOutboxEvent(keys[0])
+		require.Nil(t, err)
+		assert.Equal(t, 2, event.Attempts)
+	})
+
+	t.Run("FilePublisher", func(t *testing.T) {
+		var events []OutboxEvent
+
+		dir, err := ioutil.TempDir("", "phoenix-outbox")
+		require.Nil(t, err)
+		defer os.RemoveAll(dir)
+
+		publisher := &FilePublisher{Path: filepath.Join(dir, "events.ndjson")}
+		require.Nil(t, publisher.Publish(&OutboxEvent{Kind: EventNodeStored, ID: "/node/a"}))
+		require.Nil(t, publisher.Publish(&OutboxEvent{Kind: EventPageStored, ID: "/page/a"}))
+
+		f, err := os.Open(publisher.Path)
+		require.Nil(t, err)
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var event OutboxEvent
+			require.Nil(t, json.Unmarshal(scanner.Bytes(), &event))
+			events = append(events, event)
+		}
+
+		require.Len(t, events, 2)
+		assert.Equal(t, "/node/a", events[0].ID)
+		assert.Equal(t, EventPageStored, events[1].Kind)
+	})
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/wikimedia/phoenix/common"
+)
+
+// ReindexOptions configure a Reindex
+type ReindexOptions struct {
+	// If non-empty, only the Pages (and Nodes) of this wiki are indexed
+	Authority string
+
+	// Resumes a Reindex from a checkpoint (see: ReindexProgress.Cursor); Empty to begin at the start.
+	Cursor string
+
+	// The number of Pages indexed between checkpoints; When zero (or less), 1000.
+	BatchSize int
+
+	// If set, invoked after each batch of Pages is indexed.  Returning an error stops the Reindex (with
+	// that error).
+	Checkpoint func(progress ReindexProgress) error
+}
+
+// ReindexProgress reports the progress of a Reindex
+type ReindexProgress struct {
+	// Pass as ReindexOptions.Cursor to resume from this point; Empty once the Reindex is complete.
+	Cursor string `json:"cursor"`
+
+	// Pages, and Nodes indexed (since the Reindex started, or was resumed)
+	Pages int `json:"pages"`
+	Nodes int `json:"nodes"`
+
+	// Parts of Pages that were not indexed because they do not exist
+	MissingNodes int `json:"missingNodes"`
+}
+
+// Reindex replays the stored Pages, and their Nodes, into index (which need not be the Repository's own).  Pages
+// are indexed in order of ID, a batch at a time; After each batch, options.Checkpoint is passed a cursor from
+// which the Reindex can be resumed (index updates are idempotent, so resuming from an earlier checkpoint is
+// harmless).  The Reindex stops at the first Page that cannot be indexed; The progress returned along with the
+// error can be resumed from.
+//
+// Index entries for objects that no longer exist are not removed; Reindex into an empty index to avoid them.
+func (r *Repository) Reindex(index Index, options ReindexOptions) (*ReindexProgress, error) {
+	var progress = &ReindexProgress{Cursor: options.Cursor}
+
+	for {
+		var next string
+		var pages []*common.Page
+		var err error
+
+		if pages, next, err = r.ListPages(options.Authority, progress.Cursor, options.BatchSize); err != nil {
+			return progress, err
+		}
+
+		for _, page := range pages {
+			var nodes []*common.Node
+
+			if nodes, err = r.GetNodes(page.HasPart); err != nil {
+				return progress, fmt.Errorf("error reindexing %s: %w", page.ID, err)
+			}
+
+			update := &Update{Page: *page}
+
+			for _, node := range nodes {
+				if node == nil {
+					progress.MissingNodes++
+					continue
+				}
+				update.Nodes = append(update.Nodes, *node)
+			}
+
+			if err = index.Apply(update); err != nil {
+				return progress, fmt.Errorf("error reindexing %s: %w", page.ID, err)
+			}
+
+			progress.Pages++
+			progress.Nodes += len(update.Nodes)
+		}
+
+		// The cursor only advances once the entire batch is indexed
+		progress.Cursor = next
+
+		if options.Checkpoint != nil {
+			if err = options.Checkpoint(*progress); err != nil {
+				return progress, err
+			}
+		}
+
+		if progress.Cursor == "" {
+			return progress, nil
+		}
+	}
+}
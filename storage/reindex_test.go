@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wikimedia/phoenix/common"
+)
+
+// An Index that fails once a number of pages have been indexed
+type failingIndex struct {
+	*MockIndex
+	remaining int
+}
+
+func (i *failingIndex) Apply(update *Update) error {
+	if i.remaining == 0 {
+		return errors.New("index unavailable")
+	}
+	i.remaining--
+	return i.MockIndex.Apply(update)
+}
+
+func TestRepositoryReindex(t *testing.T) {
+	repo := Repository{Store: NewMockStore(), Index: NewMockIndex(), Bucket: "test"}
+
+	var names []string
+
+	for i := 1; i <= 5; i++ {
+		page := testPage
+		page.Source.ID = 100 + i
+		page.Name = fmt.Sprintf("Page %d", i)
+
+		node := testNode
+		node.Source = page.Source
+
+		_, err := repo.Apply(&Update{Page: page, Nodes: []common.Node{node}})
+		require.Nil(t, err)
+
+		names = append(names, page.Name)
+	}
+
+	// Checks that every page (and node) resolves by name in index
+	assertIndexed := func(t *testing.T, index Index) {
+		for _, name := range names {
+			pid, err := index.PageIDForName(testPage.Source.Authority, name)
+			require.Nil(t, err)
+			page, err := repo.GetPage(pid)
+			require.Nil(t, err)
+			assert.Equal(t, name, page.Name)
+
+			nid, err := index.NodeIDForName(testPage.Source.Authority, name, testNode.Name)
+			require.Nil(t, err)
+			assert.Equal(t, page.HasPart[0], nid)
+		}
+	}
+
+	t.Run("Reindex", func(t *testing.T) {
+		var checkpoints []ReindexProgress
+
+		index := NewMockIndex()
+		progress, err := repo.Reindex(index, ReindexOptions{
+			BatchSize: 2,
+			Checkpoint: func(progress ReindexProgress) error {
+				checkpoints = append(checkpoints, progress)
+				return nil
+			},
+		})
+		require.Nil(t, err)
+
+		assert.Equal(t, &ReindexProgress{Pages: 5, Nodes: 5}, progress)
+		require.Len(t, checkpoints, 3)
+		assert.Equal(t, 2, checkpoints[0].Pages)
+		assert.NotEqual(t, "", checkpoints[0].Cursor)
+		assert.Equal(t, "", checkpoints[2].Cursor)
+
+		assertIndexed(t, index)
+	})
+
+	t.Run("Resume", func(t *testing.T) {
+		index := &failingIndex{MockIndex: NewMockIndex(), remaining: 3}
+
+		progress, err := repo.Reindex(index, ReindexOptions{BatchSize: 2})
+		require.NotNil(t, err)
+
+		// Resumes from the last complete batch
+		assert.Equal(t, 3, progress.Pages)
+		require.NotEqual(t, "", progress.Cursor)
+
+		index.remaining = -1
+
+		progress, err = repo.Reindex(index, ReindexOptions{BatchSize: 2, Cursor: progress.Cursor})
+		require.Nil(t, err)
+		assert.Equal(t, 3, progress.Pages)
+
+		assertIndexed(t, index)
+	})
+
+	t.Run("Missing nodes", func(t *testing.T) {
+		page, err := repo.GetPageByName(testPage.Source.Authority, names[0])
+		require.Nil(t, err)
+		require.Nil(t, repo.DeleteNode(page.HasPart[0]))
+
+		progress, err := repo.Reindex(NewMockIndex(), ReindexOptions{})
+		require.Nil(t, err)
+		assert.Equal(t, &ReindexProgress{Pages: 5, Nodes: 4, MissingNodes: 1}, progress)
+	})
+}
@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/wikimedia/phoenix/common"
+)
+
+// Pages and nodes are stored twice; Once under their ID (the current version), and again in a revision history
+// keyed by ID and (MediaWiki) revision.  The revisions stored are found by listing the history of an object.
+
+// GetPageAtRevision returns a Page, by its ID, as it existed at a (MediaWiki) revision.  This is the most recent
+// revision stored that is less than or equal to the one requested.
+func (r *Repository) GetPageAtRevision(id string, revision int) (*common.Page, error) {
+	var err error
+	var rev int
+
+	if rev, err = r.resolveRevision(id, revision); err != nil {
+		return nil, err
+	}
+
+	return r.GetPage(revisionf(id, rev))
+}
+
+// GetNodeAtRevision returns a Node, by its ID, as it existed at a (MediaWiki) revision.  This is the most recent
+// revision stored that is less than or equal to the one requested.
+func (r *Repository) GetNodeAtRevision(id string, revision int) (*common.Node, error) {
+	var err error
+	var rev int
+
+	if rev, err = r.resolveRevision(id, revision); err != nil {
+		return nil, err
+	}
+
+	return r.getNode(revisionf(id, rev))
+}
+
+// ListRevisions returns the (MediaWiki) revisions stored for a Page or Node, in ascending order.
+func (r *Repository) ListRevisions(id string) ([]int, error) {
+	var err error
+	var keys []string
+	var revisions []int
+
+	if keys, err = r.listRevisionKeys(id); err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		var rev int
+
+		if rev, err = strconv.Atoi(key[len(revisionsf(id))+1:]); err != nil {
+			return nil, fmt.Errorf("invalid revision key %s: %w", key, err)
+		}
+
+		revisions = append(revisions, rev)
+	}
+
+	if len(revisions) == 0 {
+		return nil, &ErrNotFound{fmt.Sprintf("%s: no revisions stored", id)}
+	}
+
+	// Keys sort as strings, not numbers
+	sort.Ints(revisions)
+
+	return revisions, nil
+}
+
+// Returns the keys of the revisions stored for an object.
+func (r *Repository) listRevisionKeys(id string) ([]string, error) {
+	var cursor string
+	var keys []string
+
+	for {
+		var batch []string
+		var more bool
+		var err error
+
+		if batch, more, err = r.list(revisionsf(id)+"/", cursor, maxListKeys); err != nil {
+			return nil, fmt.Errorf("Error retrieving revisions: %w", err)
+		}
+
+		keys = append(keys, batch...)
+
+		if !more || len(batch) == 0 {
+			return keys, nil
+		}
+
+		cursor = batch[len(batch)-1]
+	}
+}
+
+// Returns the most recent revision of an object that is less than or equal to revision.
+func (r *Repository) resolveRevision(id string, revision int) (int, error) {
+	var err error
+	var revisions []int
+
+	if revisions, err = r.ListRevisions(id); err != nil {
+		return 0, err
+	}
+
+	// Revisions are sorted; Find the first that is greater than the one requested, and step back one.
+	i := sort.SearchInts(revisions, revision+1)
+	if i == 0 {
+		return 0, &ErrNotFound{fmt.Sprintf("%s: no revision at or before %d", id, revision)}
+	}
+
+	return revisions[i-1], nil
+}
+
+// Helper method for storing an object as both the current version, and a revision in its history.  The
+// current version is written last, so that it never refers to a revision missing from the history.
+func (r *Repository) putRevision(id string, revision int, data []byte, meta map[string]*string) error {
+	if err := r.put(revisionf(id, revision), data, meta); err != nil {
+		return err
+	}
+
+	return r.put(id, data, meta)
+}
+
+// Helper method for deleting the revision history of an object.
+func (r *Repository) deleteRevisions(id string) error {
+	var err error
+	var keys []string
+
+	if keys, err = r.listRevisionKeys(id); err != nil {
+		return err
+	}
+
+	return r.delete(keys)
+}
+
+// Return formatted keys for an object's revision history, and for the object at a revision.
+func revisionsf(id string) string {
+	return fmt.Sprintf("/history%s", id)
+}
+
+func revisionf(id string, revision int) string {
+	return fmt.Sprintf("/history%s/%d", id, revision)
+}
@@ -0,0 +1,265 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/spaolacci/murmur3"
+)
+
+// Stored objects are stamped (in their metadata) with the type, and schema version of their JSON encoding.  When
+// an object of an older version is read, it is upgraded to the current version by applying the migrations of its
+// type in turn; Objects are rewritten in the current version only when next stored (see: Repository.Migrate).
+
+// Object metadata names (S3 returns these canonicalized, for example, "Schema-Version").
+const (
+	typeMetadata          = "type"
+	schemaVersionMetadata = "schema-version"
+)
+
+// SchemaMigration upgrades the JSON-decoded form of a stored object by one schema version, in place.  Numbers are
+// decoded as json.Number.
+type SchemaMigration func(object map[string]interface{}) error
+
+// Schema migrations, by object type (the "type" metadata of stored objects).  The schema version of a type is one
+// more than the number of its migrations (objects stored before versioning was introduced are version 1), and
+// schemaMigrations[t][n] upgrades an object of type t from version n+1 to n+2.  Once released, migrations must
+// never be altered or reordered, only appended to.
+var schemaMigrations = map[string][]SchemaMigration{
+	"common.Node": {
+		// 2: Nodes have a content hash (see: makeContentHash); The hash is computed here as it was at version 2, so
+		// that migrated nodes compare equal to those stored at that version, whatever makeContentHash becomes.
+		func(object map[string]interface{}) error {
+			var name, unsafe string
+
+			if hash, _ := object["contentHash"].(string); hash != "" {
+				return nil
+			}
+
+			name, _ = object["name"].(string)
+			unsafe, _ = object["unsafe"].(string)
+
+			hasher := murmur3.New64()
+			hasher.Write([]byte(fmt.Sprintf("%d:%s", len(name), name)))
+			hasher.Write([]byte(unsafe))
+			object["contentHash"] = fmt.Sprintf("%x", hasher.Sum64())
+
+			return nil
+		},
+	},
+}
+
+// ErrSchemaMigration indicates that a stored object could not be migrated to the current schema version
+type ErrSchemaMigration struct {
+	message string
+	err     error
+}
+
+func (e *ErrSchemaMigration) Error() string {
+	return e.message
+}
+
+func (e *ErrSchemaMigration) Unwrap() error {
+	return e.err
+}
+
+// Returns the current schema version of an object type
+func schemaVersion(objectType string) int {
+	return 1 + len(schemaMigrations[objectType])
+}
+
+// Returns a copy of meta (with names lower-cased), stamped with the current schema version of its type.
+func withSchemaVersion(meta map[string]*string) map[string]*string {
+	var res = make(map[string]*string, len(meta)+1)
+
+	for k, v := range meta {
+		res[strings.ToLower(k)] = v
+	}
+
+	if objectType := metadataValue(meta, typeMetadata); objectType != "" {
+		res[schemaVersionMetadata] = aws.String(strconv.Itoa(schemaVersion(objectType)))
+	}
+
+	return res
+}
+
+// Returns the type and schema version of a stored object, from its metadata.  Objects without a (valid) schema
+// version are version 1.
+func objectSchema(meta map[string]*string) (string, int) {
+	var objectType = metadataValue(meta, typeMetadata)
+	var version int
+	var err error
+
+	if version, err = strconv.Atoi(metadataValue(meta, schemaVersionMetadata)); err != nil || version < 1 {
+		version = 1
+	}
+
+	return objectType, version
+}
+
+// Returns true if a stored object is of an older schema version than the current.
+func isOutdated(output *s3.GetObjectOutput) bool {
+	objectType, version := objectSchema(output.Metadata)
+	return version < schemaVersion(objectType)
+}
+
+// Upgrades the JSON encoding of an object of type objectType from version to the current version.
+func migrateObject(objectType string, version int, data []byte) ([]byte, error) {
+	var migrations = schemaMigrations[objectType]
+	var object map[string]interface{}
+	var b []byte
+	var err error
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	if err = decoder.Decode(&object); err != nil {
+		return nil, &ErrSchemaMigration{fmt.Sprintf("unable to migrate %s (version %d): %s", objectType, version, err), err}
+	}
+
+	for n := version - 1; n < len(migrations); n++ {
+		if err = migrations[n](object); err != nil {
+			return nil, &ErrSchemaMigration{fmt.Sprintf("unable to migrate %s to version %d: %s", objectType, n+2, err), err}
+		}
+	}
+
+	if b, err = encodeJSON(object); err != nil {
+		return nil, &ErrSchemaMigration{fmt.Sprintf("unable to migrate %s (version %d): %s", objectType, version, err), err}
+	}
+
+	return b, nil
+}
+
+// Returns the value of a metadata attribute, matching names without regard to case.
+func metadataValue(meta map[string]*string, name string) string {
+	for k, v := range meta {
+		if strings.EqualFold(k, name) {
+			return aws.StringValue(v)
+		}
+	}
+	return ""
+}
+
+// MigrateOptions configure a Migrate
+type MigrateOptions struct {
+	// Resumes a Migrate from a checkpoint (see: MigrateProgress.Cursor); Empty to begin at the start.
+	Cursor string
+
+	// Report the objects that would be migrated, without rewriting them
+	DryRun bool
+
+	// If set, invoked after each batch of (up to 1000) objects.  Returning an error stops the Migrate (with
+	// that error).
+	Checkpoint func(progress MigrateProgress) error
+}
+
+// MigrateProgress reports the progress of a Migrate
+type MigrateProgress struct {
+	// Pass as MigrateOptions.Cursor to resume from this point; Empty once the Migrate is complete.
+	Cursor string `json:"cursor"`
+
+	// Objects examined, and those migrated (or with DryRun, in need of it)
+	Objects  int `json:"objects"`
+	Migrated int `json:"migrated"`
+}
+
+// Migrate rewrites every stored object of an older schema version in the current version (objects are otherwise
+// migrated each time they are read).  Objects are examined in order of key, a batch at a time (concurrently); After
+// each batch, options.Checkpoint is passed a cursor from which the Migrate can be resumed.
+//
+// NOTE: An object updated between being read and rewritten here is overwritten with the migrated (older) copy;
+// Do not migrate a repository while it is being updated.
+func (r *Repository) Migrate(options MigrateOptions) (*MigrateProgress, error) {
+	var progress = &MigrateProgress{Cursor: options.Cursor}
+	var mutex sync.Mutex
+
+	for {
+		var keys []string
+		var more bool
+		var err error
+
+		if keys, more, err = r.list("", progress.Cursor, maxListKeys); err != nil {
+			return progress, fmt.Errorf("error listing objects: %w", err)
+		}
+
+		err = forEach(len(keys), r.concurrency(), func(i int) error {
+			var migrated bool
+			var err error
+
+			if migrated, err = r.migrate(keys[i], options.DryRun); err != nil {
+				return fmt.Errorf("error migrating %s: %w", keys[i], err)
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			progress.Objects++
+			if migrated {
+				progress.Migrated++
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return progress, err
+		}
+
+		// The cursor only advances once the entire batch is migrated
+		if more && len(keys) > 0 {
+			progress.Cursor = keys[len(keys)-1]
+		} else {
+			progress.Cursor = ""
+		}
+
+		if options.Checkpoint != nil {
+			if err = options.Checkpoint(*progress); err != nil {
+				return progress, err
+			}
+		}
+
+		if progress.Cursor == "" {
+			return progress, nil
+		}
+	}
+}
+
+// Rewrites an object in the current schema version (if it is not already); Returns true if it was outdated.
+func (r *Repository) migrate(key string, dryRun bool) (bool, error) {
+	var data []byte
+	var output *s3.GetObjectOutput
+	var err error
+
+	if output, err = r.Store.GetObject(&s3.GetObjectInput{Bucket: aws.String(r.Bucket), Key: aws.String(key)}); err != nil {
+		return false, err
+	}
+
+	defer output.Body.Close()
+
+	if !isOutdated(output) {
+		return false, nil
+	}
+
+	if dryRun {
+		return true, nil
+	}
+
+	if data, err = ioutil.ReadAll(output.Body); err != nil {
+		return false, fmt.Errorf("unable to read object body: %w", err)
+	}
+
+	objectType, version := objectSchema(output.Metadata)
+
+	if data, err = migrateObject(objectType, version, data); err != nil {
+		return false, err
+	}
+
+	return true, r.put(key, data, output.Metadata)
+}
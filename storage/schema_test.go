@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wikimedia/phoenix/common"
+)
+
+func TestSchemaMigration(t *testing.T) {
+	store := NewMockStore()
+	repo := Repository{Store: store, Index: NewMockIndex(), Bucket: "test"}
+
+	// Stores an object as it would have been before schema versioning (or content hashes)
+	putLegacy := func(key string, v interface{}, objectType string) {
+		b, err := json.Marshal(v)
+		require.Nil(t, err)
+		_, err = store.PutObject(&s3.PutObjectInput{
+			Bucket:   aws.String("test"),
+			Key:      aws.String(key),
+			Body:     aws.ReadSeekCloser(bytes.NewReader(b)),
+			Metadata: map[string]*string{"Type": aws.String(objectType)},
+		})
+		require.Nil(t, err)
+	}
+
+	legacy := testNode
+	legacy.ID = nodef(makeNodeID(&legacy))
+	legacy.ContentHash = ""
+	putLegacy(legacy.ID, &legacy, "common.Node")
+
+	page := testPage
+	page.ID = pagef(makePageID(&page))
+	page.HasPart = []string{legacy.ID}
+	putLegacy(page.ID, &page, "common.Page")
+
+	t.Run("Stamped", func(t *testing.T) {
+		node := testNode
+		node.Name = "Stamped"
+		_, err := repo.PutNode(&node)
+		require.Nil(t, err)
+		defer repo.DeleteNode(node.ID)
+
+		assert.Equal(t, "2", aws.StringValue(store.Metadata[node.ID][schemaVersionMetadata]))
+		assert.Equal(t, "2", aws.StringValue(store.Metadata[revisionf(node.ID, node.Source.Revision)][schemaVersionMetadata]))
+	})
+
+	t.Run("Frozen", func(t *testing.T) {
+		// The content hash of a migrated node is that of version 2, whatever makeContentHash becomes
+		object := map[string]interface{}{"name": "History", "unsafe": "<h1>History</h1><p>Settled in 1846...</p>"}
+		require.Nil(t, schemaMigrations["common.Node"][0](object))
+		assert.Equal(t, "88165ae8d40a5153", object["contentHash"])
+	})
+
+	t.Run("Read-time", func(t *testing.T) {
+		node, err := repo.GetNode(legacy.ID)
+		require.Nil(t, err)
+		assert.Equal(t, makeContentHash(&legacy), node.ContentHash)
+		assert.Equal(t, legacy.Source, node.Source)
+		assert.Equal(t, legacy.Unsafe, node.Unsafe)
+
+		// The stored object is unchanged
+		assert.NotContains(t, string(store.Objects[legacy.ID]), "contentHash")
+	})
+
+	t.Run("Migrate", func(t *testing.T) {
+		progress, err := repo.Migrate(MigrateOptions{DryRun: true})
+		require.Nil(t, err)
+		assert.Equal(t, &MigrateProgress{Objects: 2, Migrated: 1}, progress)
+		assert.NotContains(t, string(store.Objects[legacy.ID]), "contentHash")
+
+		progress, err = repo.Migrate(MigrateOptions{})
+		require.Nil(t, err)
+		assert.Equal(t, &MigrateProgress{Objects: 2, Migrated: 1}, progress)
+
+		assert.Contains(t, string(store.Objects[legacy.ID]), makeContentHash(&legacy))
+		assert.Equal(t, "2", aws.StringValue(store.Metadata[legacy.ID][schemaVersionMetadata]))
+		assert.Equal(t, "common.Node", aws.StringValue(store.Metadata[legacy.ID][typeMetadata]))
+
+		progress, err = repo.Migrate(MigrateOptions{})
+		require.Nil(t, err)
+		assert.Equal(t, 0, progress.Migrated)
+	})
+
+	t.Run("Registry", func(t *testing.T) {
+		defer func(migrations map[string][]SchemaMigration) { schemaMigrations = migrations }(schemaMigrations)
+
+		schemaMigrations = map[string][]SchemaMigration{
+			"common.Thing": {
+				func(object map[string]interface{}) error {
+					object["name"] = "v2"
+					return nil
+				},
+				func(object map[string]interface{}) error {
+					object["description"] = object["name"].(string) + ", v3"
+					return nil
+				},
+			},
+		}
+
+		b, err := migrateObject("common.Thing", 1, []byte(`{"name": "v1", "sameAs": "x"}`))
+		require.Nil(t, err)
+		assert.JSONEq(t, `{"name": "v2", "description": "v2, v3", "sameAs": "x"}`, string(b))
+
+		b, err = migrateObject("common.Thing", 2, []byte(`{"name": "v1"}`))
+		require.Nil(t, err)
+		assert.JSONEq(t, `{"name": "v1", "description": "v1, v3"}`, string(b))
+
+		// Failures
+		schemaMigrations["common.Thing"] = append(schemaMigrations["common.Thing"], func(map[string]interface{}) error {
+			return errors.New("boom")
+		})
+
+		var merr *ErrSchemaMigration
+		putLegacy("/data/legacy", common.NewThing(), "common.Thing")
+		_, err = repo.GetAbout("/data/legacy")
+		assert.True(t, errors.As(err, &merr))
+	})
+}
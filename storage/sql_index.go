@@ -0,0 +1,253 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Schema migrations, in the order they are to be applied.  A migration's version is its position in the list
+// (starting at 1); Once released, migrations must never be altered or reordered, only appended to.
+//
+// Statements are restricted to the SQL common to PostgreSQL and SQLite (>= 3.24).
+var sqlIndexMigrations = []string{
+	`CREATE TABLE page_names (
+		authority TEXT NOT NULL,
+		name      TEXT NOT NULL,
+		id        TEXT NOT NULL,
+		PRIMARY KEY (authority, name)
+	)`,
+	`CREATE TABLE node_names (
+		authority TEXT NOT NULL,
+		name      TEXT NOT NULL,
+		id        TEXT NOT NULL,
+		PRIMARY KEY (authority, name)
+	)`,
+}
+
+// Upserts (re)indexing a name
+const (
+	sqlUpsertPageName = `INSERT INTO page_names (authority, name, id) VALUES ($1, $2, $3)
+		ON CONFLICT (authority, name) DO UPDATE SET id = excluded.id`
+	sqlUpsertNodeName = `INSERT INTO node_names (authority, name, id) VALUES ($1, $2, $3)
+		ON CONFLICT (authority, name) DO UPDATE SET id = excluded.id`
+)
+
+// SQLIndex is a Phoenix document indexer backed by a relational database (PostgreSQL or SQLite).  Node names
+// are stored encoded (see: encodeNodeName), so lookups behave identically to the other Index implementations.
+type SQLIndex struct {
+	DB *sql.DB
+
+	ctx context.Context
+}
+
+// WithContext returns a copy of the index whose queries are made with ctx.
+func (i *SQLIndex) WithContext(ctx context.Context) Index {
+	var index = *i
+	index.ctx = ctx
+	return &index
+}
+
+// NewSQLIndex returns an SQLIndex for db, first applying any outstanding schema migrations.  The caller is
+// responsible for registering the database driver, and for closing db.
+func NewSQLIndex(db *sql.DB) (*SQLIndex, error) {
+	var index = &SQLIndex{DB: db}
+
+	if err := index.Migrate(); err != nil {
+		return nil, err
+	}
+
+	return index, nil
+}
+
+// Migrate applies any schema migrations that have not yet been applied to the database.  Each migration is
+// applied in a transaction of its own, along with the record of its having been applied.
+func (i *SQLIndex) Migrate() error {
+	var current int
+	var err error
+
+	if _, err = i.DB.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`); err != nil {
+		return fmt.Errorf("unable to create schema_migrations table: %w", err)
+	}
+
+	if err = i.DB.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("unable to determine schema version: %w", err)
+	}
+
+	for n := current; n < len(sqlIndexMigrations); n++ {
+		var version = n + 1
+
+		err = i.transaction(func(tx *sql.Tx) error {
+			if _, err := tx.Exec(sqlIndexMigrations[n]); err != nil {
+				return err
+			}
+			_, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES ($1)`, version)
+			return err
+		})
+
+		if err != nil {
+			return fmt.Errorf("unable to apply schema migration %d: %w", version, err)
+		}
+	}
+
+	return nil
+}
+
+// Apply updates the index with new Phoenix document data.  The page and all of its node names are written in
+// a single transaction; Either all of them are indexed, or none are.
+func (i *SQLIndex) Apply(update *Update) error {
+	page := update.Page
+
+	// Checked ahead of the transaction (an upsert would otherwise overwrite one with the other)
+	if err := validateNodeNames(update); err != nil {
+		return err
+	}
+
+	err := i.transaction(func(tx *sql.Tx) error {
+		var stmt *sql.Stmt
+		var err error
+
+		if _, err = tx.ExecContext(contextOrBackground(i.ctx), sqlUpsertPageName, page.Source.Authority, page.Name, page.ID); err != nil {
+			return fmt.Errorf("unable to index page name %s: %w", page.Name, err)
+		}
+
+		if stmt, err = tx.PrepareContext(contextOrBackground(i.ctx), sqlUpsertNodeName); err != nil {
+			return fmt.Errorf("unable to prepare statement: %w", err)
+		}
+
+		defer stmt.Close()
+
+		for _, n := range update.Nodes {
+			if _, err = stmt.ExecContext(contextOrBackground(i.ctx), n.Source.Authority, encodeNodeName(page.Name, n.Name), n.ID); err != nil {
+				return fmt.Errorf("unable to index node name %s: %w", n.Name, err)
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return classify(err, fmt.Sprintf("index update of %s failed", page.ID))
+	}
+
+	return nil
+}
+
+// PageIDForName queries the index for page ID matching authority (wiki) and name
+func (i *SQLIndex) PageIDForName(authority, name string) (string, error) {
+	var id string
+
+	err := i.DB.QueryRowContext(contextOrBackground(i.ctx), `SELECT id FROM page_names WHERE authority = $1 AND name = $2`, authority, name).Scan(&id)
+
+	if err == sql.ErrNoRows {
+		return "", &ErrNotFound{fmt.Sprintf("page index: %s/%s not found", authority, name)}
+	}
+
+	if err != nil {
+		return "", classify(err, "page index query failed")
+	}
+
+	return id, nil
+}
+
+// NodeIDForName queries the index for node ID matching authority (wiki), page name, and name
+func (i *SQLIndex) NodeIDForName(authority, pageName, name string) (string, error) {
+	var id string
+
+	err := i.DB.QueryRowContext(contextOrBackground(i.ctx), `SELECT id FROM node_names WHERE authority = $1 AND name = $2`, authority, encodeNodeName(pageName, name)).Scan(&id)
+
+	if err == sql.ErrNoRows {
+		return "", &ErrNotFound{fmt.Sprintf("node index: %s/%s/%s not found", authority, pageName, name)}
+	}
+
+	if err != nil {
+		return "", classify(err, "node index query failed")
+	}
+
+	return id, nil
+}
+
+// RemovePage removes the index entry for a page name
+func (i *SQLIndex) RemovePage(authority, name string) error {
+	if _, err := i.DB.ExecContext(contextOrBackground(i.ctx), `DELETE FROM page_names WHERE authority = $1 AND name = $2`, authority, name); err != nil {
+		return classify(err, fmt.Sprintf("unable to remove page name %s", name))
+	}
+	return nil
+}
+
+// RemoveNode removes the index entry for a node name
+func (i *SQLIndex) RemoveNode(authority, pageName, name string) error {
+	if _, err := i.DB.ExecContext(contextOrBackground(i.ctx), `DELETE FROM node_names WHERE authority = $1 AND name = $2`, authority, encodeNodeName(pageName, name)); err != nil {
+		return classify(err, fmt.Sprintf("unable to remove node name %s", name))
+	}
+	return nil
+}
+
+// ScanPages invokes f for each page name entry
+func (i *SQLIndex) ScanPages(f func(authority, name, id string) error) error {
+	return i.scan(`SELECT authority, name, id FROM page_names ORDER BY authority, name`, func(authority, name, id string) error {
+		return f(authority, name, id)
+	})
+}
+
+// ScanNodes invokes f for each node name entry
+func (i *SQLIndex) ScanNodes(f func(authority, pageName, name, id string) error) error {
+	return i.scan(`SELECT authority, name, id FROM node_names ORDER BY authority, name`, func(authority, encoded, id string) error {
+		pageName, name, err := decodeNodeName(encoded)
+		if err != nil {
+			return err
+		}
+		return f(authority, pageName, name, id)
+	})
+}
+
+// Invokes f for each row of a query (selecting an authority, name, and ID).  Rows are read in full before the
+// callback is invoked, so that it may itself modify the index (SQLite permits only a single connection to write).
+func (i *SQLIndex) scan(query string, f func(authority, name, id string) error) error {
+	var entries [][3]string
+	var rows *sql.Rows
+	var err error
+
+	if rows, err = i.DB.QueryContext(contextOrBackground(i.ctx), query); err != nil {
+		return fmt.Errorf("index query failed: %w", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var e [3]string
+		if err = rows.Scan(&e[0], &e[1], &e[2]); err != nil {
+			return fmt.Errorf("index query failed: %w", err)
+		}
+		entries = append(entries, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("index query failed: %w", err)
+	}
+
+	for _, e := range entries {
+		if err = f(e[0], e[1], e[2]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Invokes f inside a transaction, committing if it succeeds, and rolling back otherwise.
+func (i *SQLIndex) transaction(f func(tx *sql.Tx) error) error {
+	var tx *sql.Tx
+	var err error
+
+	if tx, err = i.DB.BeginTx(contextOrBackground(i.ctx), nil); err != nil {
+		return fmt.Errorf("unable to begin transaction: %w", err)
+	}
+
+	if err = f(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
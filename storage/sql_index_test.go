@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wikimedia/phoenix/common"
+)
+
+func TestSQLIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phoenix-index")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "index.db"))
+	require.Nil(t, err)
+	defer db.Close()
+
+	index, err := NewSQLIndex(db)
+	require.Nil(t, err)
+
+	testIndex(t, index)
+
+	t.Run("Migrations are idempotent", func(t *testing.T) {
+		var version int
+
+		_, err := NewSQLIndex(db)
+		require.Nil(t, err)
+
+		require.Nil(t, db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version))
+		assert.Equal(t, len(sqlIndexMigrations), version)
+	})
+
+	t.Run("No batch size limit", func(t *testing.T) {
+		var nodes []common.Node
+		var source = common.Source{Authority: "fake.wikipedia.org"}
+
+		for n := 0; n < 100; n++ {
+			nodes = append(nodes, common.Node{ID: fmt.Sprintf("/node/%d", n), Name: fmt.Sprintf("Section %d", n), Source: source})
+		}
+
+		require.Nil(t, index.Apply(&Update{Page: common.Page{ID: "/page/c0c0c0c0c0c0c", Name: "Big", Source: source}, Nodes: nodes}))
+
+		for _, n := range nodes {
+			id, err := index.NodeIDForName("fake.wikipedia.org", "Big", n.Name)
+			require.Nil(t, err)
+			assert.Equal(t, n.ID, id)
+		}
+	})
+
+	t.Run("Updates are transactional", func(t *testing.T) {
+		var source = common.Source{Authority: "fake.wikipedia.org"}
+
+		// Make writes of node names fail
+		_, err := db.Exec(`CREATE TRIGGER fail BEFORE INSERT ON node_names BEGIN SELECT RAISE(ABORT, 'failed'); END`)
+		require.Nil(t, err)
+		defer db.Exec(`DROP TRIGGER fail`)
+
+		err = index.Apply(
+			&Update{
+				Page:  common.Page{ID: "/page/d0d0d0d0d0d0d", Name: "Rollback", Source: source},
+				Nodes: []common.Node{{ID: "/node/d0d0d0d0d0d0d", Name: "History", Source: source}},
+			})
+		require.NotNil(t, err)
+
+		_, err = index.PageIDForName("fake.wikipedia.org", "Rollback")
+		_, ok := err.(*ErrNotFound)
+		require.True(t, ok, "Expected an error of type ErrNotFound")
+	})
+}
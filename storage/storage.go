@@ -6,7 +6,10 @@ import (
 	"errors"
 	"fmt"
 	"hash"
+	"io/ioutil"
 	"regexp"
+	"strings"
+	"sync"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -16,20 +19,12 @@ import (
 	"github.com/wikimedia/phoenix/common"
 )
 
-// ErrNotFound indicates that a requested resource does not exist in storage
-type ErrNotFound struct {
-	message string
-}
-
-func (e *ErrNotFound) Error() string {
-	return e.message
-}
-
 // Store is a mockable interface corresponding to s3.S3.
 type Store interface {
 	PutObject(*s3.PutObjectInput) (*s3.PutObjectOutput, error)
 	GetObject(*s3.GetObjectInput) (*s3.GetObjectOutput, error)
 	DeleteObjects(*s3.DeleteObjectsInput) (*s3.DeleteObjectsOutput, error)
+	ListObjectsV2(*s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error)
 }
 
 // Repository provides read/write access to the Phoenix Content Repository.
@@ -37,9 +32,33 @@ type Repository struct {
 	Store  Store
 	Index  Index
 	Bucket string
+
+	// TopicSearch is optional; When set, topic search entries are removed along with the nodes they refer to.
+	TopicSearch TopicSearch
+
+	// Hooks is optional; When set, its subscribers are notified of the changes made by Apply and DeletePage.
+	Hooks *Hooks
+
+	// Outbox is optional; When set, the events of Apply and DeletePage are recorded for delivery by a Relay.
+	Outbox *Outbox
+
+	// The maximum number of concurrent requests made by Apply, GetNodes, and GetPages; When unset (zero),
+	// defaultConcurrency is used.
+	Concurrency int
 }
 
-// Helper method for downloading files from S3.
+// The default maximum number of concurrent requests made by a Repository
+const defaultConcurrency = 8
+
+func (r *Repository) concurrency() int {
+	if r.Concurrency > 0 {
+		return r.Concurrency
+	}
+	return defaultConcurrency
+}
+
+// Helper method for downloading files from S3.  Objects of an older schema version are upgraded to the current
+// one (see: schema.go).
 func (r *Repository) get(key string) (*json.Decoder, error) {
 	var input *s3.GetObjectInput
 	var output *s3.GetObjectOutput
@@ -47,20 +66,49 @@ func (r *Repository) get(key string) (*json.Decoder, error) {
 
 	input = &s3.GetObjectInput{Bucket: aws.String(r.Bucket), Key: aws.String(key)}
 	if output, err = r.Store.GetObject(input); err != nil {
-		// Special-case a not found error, and return our own type (to simplify error handling for callers)
-		var s3err awserr.Error
-		if errors.As(err, &s3err) {
-			if s3err.Code() == s3.ErrCodeNoSuchKey {
-				return nil, &ErrNotFound{fmt.Sprintf("s3 resource: %s/%s not found", r.Bucket, key)}
-			}
+		return nil, classify(err, fmt.Sprintf("s3 resource: %s/%s", r.Bucket, key))
+	}
+
+	// Objects of an older schema version are migrated as they are read
+	if isOutdated(output) {
+		var data []byte
+
+		defer output.Body.Close()
+
+		if data, err = ioutil.ReadAll(output.Body); err != nil {
+			return nil, fmt.Errorf("unable to read %s: %w", key, err)
 		}
-		return nil, err
+
+		objectType, version := objectSchema(output.Metadata)
+
+		if data, err = migrateObject(objectType, version, data); err != nil {
+			return nil, fmt.Errorf("unable to read %s: %w", key, err)
+		}
+
+		return json.NewDecoder(bytes.NewReader(data)), nil
 	}
 
 	return json.NewDecoder(output.Body), nil
 }
 
-// Helper method for uploading files to S3.
+// Helper method for downloading files from S3 as (JSON) bytes.
+func (r *Repository) getRaw(key string) ([]byte, error) {
+	var data *json.Decoder
+	var err error
+	var raw json.RawMessage
+
+	if data, err = r.get(key); err != nil {
+		return nil, err
+	}
+
+	if err = data.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("Unable to deserialize JSON: %w", err)
+	}
+
+	return raw, nil
+}
+
+// Helper method for uploading files to S3.  Objects are stamped with the current schema version of their type.
 func (r *Repository) put(key string, data []byte, meta map[string]*string) error {
 	_, err := r.Store.PutObject(
 		&s3.PutObjectInput{
@@ -68,30 +116,172 @@ func (r *Repository) put(key string, data []byte, meta map[string]*string) error
 			Bucket:      aws.String(r.Bucket),
 			Key:         aws.String(key),
 			ContentType: aws.String("application/json"),
-			Metadata:    meta,
+			Metadata:    withSchemaVersion(meta),
 		})
 
-	return err
+	return classify(err, fmt.Sprintf("unable to store %s/%s", r.Bucket, key))
 }
 
 // Helper method for deleting files from S3.
 func (r *Repository) delete(keys []string) error {
-	var objects []*s3.ObjectIdentifier
+	// S3 accepts at most 1000 keys per DeleteObjects request.
+	for start := 0; start < len(keys); start += maxDeleteKeys {
+		var objects []*s3.ObjectIdentifier
+		var output *s3.DeleteObjectsOutput
+		var err error
+
+		end := start + maxDeleteKeys
+		if end > len(keys) {
+			end = len(keys)
+		}
 
-	for _, key := range keys {
-		objects = append(objects, &s3.ObjectIdentifier{Key: aws.String(key)})
+		for _, key := range keys[start:end] {
+			objects = append(objects, &s3.ObjectIdentifier{Key: aws.String(key)})
+		}
+
+		output, err = r.Store.DeleteObjects(
+			&s3.DeleteObjectsInput{
+				Bucket: aws.String(r.Bucket),
+				Delete: &s3.Delete{
+					Objects: objects,
+					Quiet:   aws.Bool(true),
+				},
+			})
+
+		if err != nil {
+			return classify(err, fmt.Sprintf("unable to delete from %s", r.Bucket))
+		}
+
+		// A successful response can still contain per-key failures.
+		if len(output.Errors) > 0 {
+			e := output.Errors[0]
+			err = awserr.New(aws.StringValue(e.Code), aws.StringValue(e.Message), nil)
+			return classify(err, fmt.Sprintf("unable to delete %s/%s", r.Bucket, aws.StringValue(e.Key)))
+		}
 	}
 
-	_, err := r.Store.DeleteObjects(
-		&s3.DeleteObjectsInput{
-			Bucket: aws.String(r.Bucket),
-			Delete: &s3.Delete{
-				Objects: objects,
-				Quiet:   aws.Bool(false),
-			},
-		})
+	return nil
+}
+
+// Helper method for listing keys in S3; Returns up to limit keys that begin with prefix (and follow after, when
+// set), and whether or not there are more.
+func (r *Repository) list(prefix, after string, limit int) ([]string, bool, error) {
+	var keys []string
+	var output *s3.ListObjectsV2Output
+	var err error
+
+	input := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(r.Bucket),
+		Prefix:  aws.String(prefix),
+		MaxKeys: aws.Int64(int64(limit)),
+	}
+
+	if after != "" {
+		input.StartAfter = aws.String(after)
+	}
+
+	if output, err = r.Store.ListObjectsV2(input); err != nil {
+		return nil, false, classify(err, fmt.Sprintf("unable to list %s/%s", r.Bucket, prefix))
+	}
+
+	for _, object := range output.Contents {
+		keys = append(keys, aws.StringValue(object.Key))
+	}
+
+	return keys, aws.BoolValue(output.IsTruncated), nil
+}
+
+// ListPages returns up to limit Pages, in order of ID, beginning after cursor (pass an empty cursor to begin at
+// the start).  If authority is non-empty, only the Pages of that wiki are returned.  The cursor returned is used
+// to retrieve the next batch; It is empty once there are no more.  When limit is zero (or less), up to 1000 Pages
+// are returned.
+func (r *Repository) ListPages(authority, cursor string, limit int) ([]*common.Page, string, error) {
+	var pages []*common.Page
 
-	return err
+	if limit <= 0 {
+		limit = maxListKeys
+	}
+
+	for {
+		var batch []*common.Page
+		var keys []string
+		var more bool
+		var err error
+
+		if keys, more, err = r.list(pagef(""), cursor, limit-len(pages)); err != nil {
+			return nil, "", fmt.Errorf("error listing pages: %w", err)
+		}
+
+		if batch, err = r.GetPages(keys); err != nil {
+			return nil, "", err
+		}
+
+		for i, page := range batch {
+			cursor = keys[i]
+
+			// Removed since listing, or of a different wiki
+			if page == nil || (authority != "" && page.Source.Authority != authority) {
+				continue
+			}
+
+			pages = append(pages, page)
+		}
+
+		if !more {
+			return pages, "", nil
+		}
+
+		if len(pages) >= limit {
+			return pages, cursor, nil
+		}
+	}
+}
+
+// ListNodes returns up to limit Nodes, in order of ID, beginning after cursor (pass an empty cursor to begin at
+// the start).  Nodes are returned as stored, whether or not they are a part of a published Page (see: GetNode).
+// If authority is non-empty, only the Nodes of that wiki are returned.  The cursor returned is used
+// to retrieve the next batch; It is empty once there are no more.  When limit is zero (or less), up to 1000 Nodes
+// are returned.
+func (r *Repository) ListNodes(authority, cursor string, limit int) ([]*common.Node, string, error) {
+	var nodes []*common.Node
+
+	if limit <= 0 {
+		limit = maxListKeys
+	}
+
+	for {
+		var batch []*common.Node
+		var keys []string
+		var more bool
+		var err error
+
+		if keys, more, err = r.list(nodef(""), cursor, limit-len(nodes)); err != nil {
+			return nil, "", fmt.Errorf("error listing nodes: %w", err)
+		}
+
+		if batch, err = r.getNodes(keys, r.getNode); err != nil {
+			return nil, "", err
+		}
+
+		for i, node := range batch {
+			cursor = keys[i]
+
+			// Removed since listing, or of a different wiki
+			if node == nil || (authority != "" && node.Source.Authority != authority) {
+				continue
+			}
+
+			nodes = append(nodes, node)
+		}
+
+		if !more {
+			return nodes, "", nil
+		}
+
+		if len(nodes) >= limit {
+			return nodes, cursor, nil
+		}
+	}
 }
 
 // GetPage returns a Page by its ID
@@ -125,14 +315,76 @@ func (r *Repository) GetPageByName(authority, name string) (*common.Page, error)
 	return r.GetPage(id)
 }
 
-// GetNode returns a Node by its ID
+// GetNode returns a Node by its ID, as it is a part of the published version of its Page; A Node that is not a
+// part of it (one of an update that is not yet published, or that is about to be garbage-collected) is not
+// found.  A Node changed by the published version, but not yet promoted (see: Apply), is read from its revision
+// history.
 func (r *Repository) GetNode(id string) (*common.Node, error) {
+	var node *common.Node
+	var page *common.Page
+	var err error
+
+	if node, err = r.getNode(id); err != nil {
+		return nil, err
+	}
+
+	if len(node.IsPartOf) == 0 {
+		return node, nil
+	}
+
+	if page, err = r.GetPage(node.IsPartOf[0]); err != nil {
+		if !isNotFound(err) {
+			return nil, err
+		}
+
+		// The Page of a first update that is not yet published has a revision, but no current object; Nodes
+		// stored without a Page (see: PutNode) are returned as is.
+		if _, err = r.getRaw(revisionf(node.IsPartOf[0], node.Source.Revision)); err == nil {
+			return nil, &ErrNotFound{fmt.Sprintf("node %s is not a part of a published page", id)}
+		} else if !isNotFound(err) {
+			return nil, err
+		}
+
+		return node, nil
+	}
+
+	if !contains(page.HasPart, id) {
+		return nil, &ErrNotFound{fmt.Sprintf("node %s is not a part of %s", id, page.ID)}
+	}
+
+	return r.publishedNode(node, page)
+}
+
+// Returns the version of node that is a part of page; node, unless it is changed by page, but yet to be promoted
+// (in which case it is read from its revision history).
+func (r *Repository) publishedNode(node *common.Node, page *common.Page) (*common.Node, error) {
+	var published *common.Node
+	var err error
+
+	if node.Source == page.Source {
+		return node, nil
+	}
+
+	// Otherwise, the Node is either unchanged since an earlier revision, or yet to be promoted
+	if published, err = r.getNode(revisionf(node.ID, page.Source.Revision)); err != nil && !isNotFound(err) {
+		return nil, err
+	}
+
+	if published != nil && published.Source == page.Source {
+		return published, nil
+	}
+
+	return node, nil
+}
+
+// Returns the Node stored under key, as is
+func (r *Repository) getNode(key string) (*common.Node, error) {
 	var data *json.Decoder
 	var err error
 	var section common.Node
 
 	// Fetch
-	if data, err = r.get(id); err != nil {
+	if data, err = r.get(key); err != nil {
 		return nil, fmt.Errorf("Error retrieving content: %w", err)
 	}
 
@@ -156,6 +408,63 @@ func (r *Repository) GetNodeByName(authority, pageName, name string) (*common.No
 	return r.GetNode(id)
 }
 
+// GetPages returns Pages by their IDs, retrieved concurrently.  Results are in the order of ids; The result of a
+// Page that does not exist is nil (errors of any other kind fail the batch).
+func (r *Repository) GetPages(ids []string) ([]*common.Page, error) {
+	var pages = make([]*common.Page, len(ids))
+
+	err := forEach(len(ids), r.concurrency(), func(i int) error {
+		var err error
+
+		if pages[i], err = r.GetPage(ids[i]); err != nil {
+			var nerr *ErrNotFound
+			if errors.As(err, &nerr) {
+				return nil
+			}
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pages, nil
+}
+
+// GetNodes returns Nodes by their IDs, retrieved concurrently.  Results are in the order of ids; The result of a
+// Node that does not exist is nil (errors of any other kind fail the batch).
+func (r *Repository) GetNodes(ids []string) ([]*common.Node, error) {
+	return r.getNodes(ids, r.GetNode)
+}
+
+// Returns Nodes by their IDs, as GetNodes does, each retrieved with get
+func (r *Repository) getNodes(ids []string, get func(id string) (*common.Node, error)) ([]*common.Node, error) {
+	var nodes = make([]*common.Node, len(ids))
+
+	err := forEach(len(ids), r.concurrency(), func(i int) error {
+		var err error
+
+		if nodes[i], err = get(ids[i]); err != nil {
+			var nerr *ErrNotFound
+			if errors.As(err, &nerr) {
+				return nil
+			}
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return nodes, nil
+}
+
 // GetAbout returns an About by its ID
 func (r *Repository) GetAbout(id string) (*common.Thing, error) {
 	var data *json.Decoder
@@ -197,8 +506,8 @@ func (r *Repository) GetTopics(node *common.Node) ([]common.RelatedTopic, error)
 	return topics, nil
 }
 
-// PutPage stores a Page. This method generates a unique ID and returns it on success; NOTE: If
-// you assign an ID it will be overwritten.
+// PutPage stores a Page, retaining a copy in the page's revision history. This method generates a
+// unique ID and returns it on success; NOTE: If you assign an ID it will be overwritten.
 func (r *Repository) PutPage(page *common.Page) (string, error) {
 	var data []byte
 	var err error
@@ -213,34 +522,24 @@ func (r *Repository) PutPage(page *common.Page) (string, error) {
 		return "", err
 	}
 
-	metadata := map[string]*string{"type": aws.String("common.Page")}
-
-	if err = r.put(page.ID, data, metadata); err != nil {
+	if err = r.putRevision(page.ID, page.Source.Revision, data, pageMetadata()); err != nil {
 		return "", err
 	}
 
 	return page.ID, nil
 }
 
-// PutNode stores a Node.  This method generates a unique ID and returns it on success; NOTE: If
-// you assign an ID it will be overwritten.
+// PutNode stores a Node, retaining a copy in the node's revision history.  This method generates a
+// unique ID and returns it on success; NOTE: If you assign an ID it will be overwritten.
 func (r *Repository) PutNode(node *common.Node) (string, error) {
 	var data []byte
 	var err error
 
-	if err = validateNode(node); err != nil {
-		return "", err
-	}
-
-	node.ID = nodef(makeNodeID(node))
-
-	if data, err = encodeJSON(node); err != nil {
+	if data, err = encodeNode(node); err != nil {
 		return "", err
 	}
 
-	metadata := map[string]*string{"type": aws.String("common.Node")}
-
-	if err = r.put(node.ID, data, metadata); err != nil {
+	if err = r.putRevision(node.ID, node.Source.Revision, data, nodeMetadata()); err != nil {
 		return "", err
 	}
 
@@ -259,9 +558,7 @@ func (r *Repository) PutAbout(thing *common.Thing) (string, error) {
 		return "", err
 	}
 
-	metadata := map[string]*string{"type": aws.String("common.Thing")}
-
-	if err = r.put(thing.ID, data, metadata); err != nil {
+	if err = r.put(thing.ID, data, aboutMetadata()); err != nil {
 		return "", err
 	}
 
@@ -282,113 +579,772 @@ func (r *Repository) PutTopics(node *common.Node, topics []common.RelatedTopic)
 	return r.put(id, data, metadata)
 }
 
-// DeletePage removes a Page from storage by its ID
-func (r *Repository) DeletePage(id string) {
-	// TODO: Do.
+// DeletePage removes a Page from storage by its ID.  Deletion cascades to the Nodes, linked-data objects,
+// and related topics that belong to the Page, as well as to the corresponding index and topic search entries.
+// Once the Page is deleted, hooks are notified (see: EventNodeRemoved, and EventPageDeleted).
+func (r *Repository) DeletePage(id string) error {
+	var err error
+	var events []*Event
+	var orphans []string
+	var page *common.Page
+
+	if page, err = r.GetPage(id); err != nil {
+		return err
+	}
+
+	for _, nid := range page.HasPart {
+		events = append(events, &Event{Kind: EventNodeRemoved, ID: nid, Page: page})
+	}
+
+	events = append(events, &Event{Kind: EventPageDeleted, ID: page.ID, Page: page})
+
+	// Events are recorded to the outbox (if any) before anything is deleted
+	if _, err = r.record(events, nil); err != nil {
+		return err
+	}
+
+	// Index entries go first, so that by-name lookups never resolve to objects that have been deleted.  The
+	// Page object itself goes last; Should anything in between fail, the operation can be retried.
+	for _, nid := range page.HasPart {
+		var node *common.Node
+
+		if node, err = r.getNode(nid); err != nil {
+			// Nothing to unindex if the node is already gone
+			var nerr *ErrNotFound
+			if errors.As(err, &nerr) {
+				continue
+			}
+			return err
+		}
+
+		if err = r.Index.RemoveNode(page.Source.Authority, page.Name, node.Name); err != nil {
+			return fmt.Errorf("error removing node from index: %w", err)
+		}
+	}
+
+	if err = r.Index.RemovePage(page.Source.Authority, page.Name); err != nil {
+		return fmt.Errorf("error removing page from index: %w", err)
+	}
+
+	for _, nid := range page.HasPart {
+		if err = r.DeleteNode(nid); err != nil {
+			return fmt.Errorf("error deleting node: %w", err)
+		}
+	}
+
+	for _, aid := range page.About {
+		if err = r.DeleteAbout(aid); err != nil {
+			return fmt.Errorf("error deleting linked data object: %w", err)
+		}
+	}
+
+	// Nodes of previous revisions that were since removed retain their history (see: removeOrphans)
+	if orphans, err = r.orphanedParts(page); err != nil {
+		return err
+	}
+
+	for _, nid := range orphans {
+		if err = r.deleteRevisions(nid); err != nil {
+			return fmt.Errorf("error deleting node revisions: %w", err)
+		}
+	}
+
+	if err = r.deleteRevisions(page.ID); err != nil {
+		return fmt.Errorf("error deleting page revisions: %w", err)
+	}
+
+	if err = r.delete([]string{page.ID}); err != nil {
+		return err
+	}
+
+	return r.Hooks.emit(events...)
+}
+
+// Returns the IDs of the Nodes that were a part of previous revisions of a Page, but are not of the current one.
+func (r *Repository) orphanedParts(page *common.Page) ([]string, error) {
+	var err error
+	var orphans []string
+	var revisions []int
+	var seen = make(map[string]bool)
+
+	for _, id := range page.HasPart {
+		seen[id] = true
+	}
+
+	if revisions, err = r.ListRevisions(page.ID); err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	for _, rev := range revisions {
+		var prev *common.Page
+
+		if prev, err = r.GetPage(revisionf(page.ID, rev)); err != nil {
+			if isNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		for _, id := range prev.HasPart {
+			if !seen[id] {
+				seen[id] = true
+				orphans = append(orphans, id)
+			}
+		}
+	}
+
+	return orphans, nil
+}
+
+// DeleteNode removes a Node from storage by its ID, along with its revision history and related topics (and
+// the corresponding topic search entries).  NOTE: The Node's index entry is not removed; Use DeletePage to
+// remove a document in its entirety.
+func (r *Repository) DeleteNode(id string) error {
+	if err := r.deleteRevisions(id); err != nil {
+		return fmt.Errorf("error deleting node revisions: %w", err)
+	}
+
+	return r.deleteNode(id)
 }
 
-// DeleteNode removes a Node from storage by its ID
-func (r *Repository) DeleteNode(id string) {
-	// TODO: Do.
+// Removes the current version of a Node, and its related topics, but retains the Node's revision history
+// (previous revisions of the Page may still refer to it).
+func (r *Repository) deleteNode(id string) error {
+	if r.TopicSearch != nil {
+		if err := r.TopicSearch.Delete(id); err != nil {
+			return fmt.Errorf("error removing related topics from search: %w", err)
+		}
+	}
+
+	return r.delete([]string{id, topicsf(strings.TrimPrefix(id, nodef("")))})
 }
 
 // DeleteAbout removes a Thing from storage by its ID
-func (r *Repository) DeleteAbout(id string) {
-	// TODO: Do.
+func (r *Repository) DeleteAbout(id string) error {
+	return r.delete([]string{id})
 }
 
 // Update encapsulates the parts of a document involved in an update of the content repository.
 type Update struct {
-	Page                common.Page
-	Nodes               []common.Node
-	Abouts              map[string]common.Thing
-	PostPutNodeCallback func(common.Node) error
+	Page   common.Page
+	Nodes  []common.Node
+	Abouts map[string]common.Thing
+
+	// Force applies the update even if it is older than the revision already stored (for intentional rollbacks).
+	Force bool
+}
+
+// Statuses of the Nodes of an UpdateResult
+const (
+	// Stored for the first time
+	NodeCreated = "created"
+
+	// Content differed from the stored version, and was overwritten
+	NodeUpdated = "updated"
+
+	// Content matched the stored version; Neither stored, nor emitted (see: EventNodeStored)
+	NodeUnchanged = "unchanged"
+
+	// A part of the previous version of the document, but not of this one
+	NodeRemoved = "removed"
+)
+
+// NodeResult reports the effect of an Apply on a Node
+type NodeResult struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+
+	// One of the Node* statuses
+	Status string `json:"status"`
 }
 
-// Apply updates a document in the content repository.
-func (r *Repository) Apply(update *Update) error {
-	var prePID, postPID string
+// UpdateResult reports the effect of an Apply on a document.  Renaming a section removes a Node, and adds
+// another.
+type UpdateResult struct {
+	PageID string `json:"pageId"`
+
+	// Revision of the document, and of the version it replaced (zero if it had not been stored)
+	Revision         int `json:"revision"`
+	PreviousRevision int `json:"previousRevision"`
+
+	// The Nodes of the document (in document order), followed by those removed
+	Nodes []NodeResult `json:"nodes"`
+
+	// IDs of the linked-data objects written, and of those (of the previous version) deleted
+	AboutsWritten []string `json:"aboutsWritten"`
+	AboutsDeleted []string `json:"aboutsDeleted"`
+
+	// True once the document is indexed; Otherwise, the names that were not (see: ErrPartialIndex), if known.
+	Indexed   bool     `json:"indexed"`
+	Unindexed []string `json:"unindexed,omitempty"`
+}
+
+// Returns a copy of an Update, whose Page and Nodes can be assigned to without affecting the original
+func (u *Update) clone() *Update {
+	var c = *u
+	c.Nodes = append([]common.Node(nil), u.Nodes...)
+	return &c
+}
+
+// Apply updates a document in the content repository.  Nodes whose content is unchanged since the stored
+// version are skipped (the stored Node retains its Source and DateModified attributes).
+//
+// Publication is all-or-nothing: The write of the Page object publishes the update.  Node revisions, new nodes,
+// linked-data objects, and the Page's revision are written ahead of it, under keys that no published version
+// refers to (new nodes are not found until a published Page refers to them).  Should any of these writes fail,
+// they are removed, and overwritten objects (the revision-scoped objects of a re-applied revision) are restored,
+// leaving the previously published version intact.  Changed nodes are promoted (overwritten) once the Page is
+// written; GetNode resolves a Node through its published Page, so readers observe either the previous version,
+// or this one.  Failures after the Page is written (promotion, indexing, garbage collection) are returned along
+// with the result, as are the errors of hooks (see: Hooks), and are repaired by applying the update again.
+//
+// The IDs of the Page and Nodes are reported in the result; update itself is not modified.
+func (r *Repository) Apply(update *Update) (*UpdateResult, error) {
+	var prePID string
 	var prevPage *common.Page
+	var prevParts = make(map[string]bool)
+	var prevAbouts = make(map[string]bool)
+	var result = &UpdateResult{}
+	var events []*Event
+	var pending []*OutboxEvent
+	var staged []string
+	var saved []savedObject
+	var mutex sync.Mutex
+	var pageData []byte
 	var err error
 
 	// Baby steps: An argument could be made for breaking down the steps here into events that
-	// trigger the respective actions, but we're not going there just yet.  An argument could
-	// also be made for handling some of these steps concurrently (we could easily parallelize
-	// uploads of Node & Things, for example), but we're not going there yet either.
+	// trigger the respective actions, but we're not going there just yet.
+
+	// IDs (and the like) are assigned to a copy; The caller's update is left as is.
+	update = update.clone()
+
+	if err = validateSource(&update.Page.Source); err != nil {
+		return nil, err
+	}
 
-	validateSource(&update.Page.Source)
 	prePID = pagef(makePageID(&update.Page))
 
 	if prevPage, err = r.GetPage(prePID); err != nil {
 		// Continue for ErrNotFound (first write?), return errors of any other type.
 		var nerr *ErrNotFound
 		if !errors.As(err, &nerr) {
+			return nil, err
+		}
+	}
+
+	result.PageID = prePID
+	result.Revision = update.Page.Source.Revision
+
+	if prevPage != nil {
+		result.PreviousRevision = prevPage.Source.Revision
+	}
+
+	// Events are delivered without ordering guarantees; Refuse to overwrite a newer revision with an older one.
+	if prevPage != nil && !update.Force && isOlder(update.Page.Source, prevPage.Source) {
+		return nil, &ErrStaleRevision{
+			message: fmt.Sprintf(
+				"stale revision: %s revision %d (tid=%s) is older than stored revision %d (tid=%s)",
+				prePID,
+				update.Page.Source.Revision,
+				update.Page.Source.TimeUUID,
+				prevPage.Source.Revision,
+				prevPage.Source.TimeUUID)}
+	}
+
+	if prevPage != nil {
+		for _, id := range prevPage.HasPart {
+			prevParts[id] = true
+		}
+		for _, id := range prevPage.About {
+			prevAbouts[id] = true
+		}
+	}
+
+	// Re-rendering (or rolling back to) a revision already stored overwrites revision-scoped objects that the
+	// published version may refer to; These must survive a rollback.
+	reapplied := prevPage != nil && update.Page.Source.Revision <= prevPage.Source.Revision
+
+	stage := func(key string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		staged = append(staged, key)
+	}
+
+	// Saves the stored version of an object that is about to be overwritten, so that a rollback can restore it
+	// (an object that does not exist yet is staged instead).
+	save := func(key string, meta map[string]*string) error {
+		var data []byte
+		var err error
+
+		if data, err = r.getRaw(key); err != nil {
+			if isNotFound(err) {
+				stage(key)
+				return nil
+			}
 			return err
 		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		saved = append(saved, savedObject{key: key, data: data, meta: meta})
+
+		return nil
 	}
 
-	update.Page.HasPart = make([]string, 0)
+	update.Page.HasPart = make([]string, len(update.Nodes))
+	unchanged := make([]bool, len(update.Nodes))
+	encoded := make([][]byte, len(update.Nodes))
 
-	// Upload node objects.  Remember: the ordering of HasPart matters (keep this in mind
-	// when/if adding concurrency at a later date).
-	for i, node := range update.Nodes {
-		var id string
+	// Stage node revisions, concurrently.  Remember: the ordering of HasPart matters; Each node is
+	// assigned the position in HasPart corresponding to its position in update.Nodes.
+	err = forEach(len(update.Nodes), r.concurrency(), func(i int) error {
 		var err error
+		var node = update.Nodes[i]
+		var key string
 
 		node.IsPartOf = []string{prePID}
 		node.Source = update.Page.Source
 
-		if id, err = r.PutNode(&node); err != nil {
-			return fmt.Errorf("error storing node: %w", err)
+		if encoded[i], err = encodeNode(&node); err != nil {
+			return err
+		}
+
+		update.Nodes[i] = node
+		update.Page.HasPart[i] = node.ID
+
+		// Only a node that was a part of the previous version can be unchanged
+		if prevParts[node.ID] {
+			if unchanged[i], err = r.isUnchanged(&node, prevPage); err != nil {
+				return err
+			}
 		}
 
-		update.Nodes[i].ID = id
-		update.Page.HasPart = append(update.Page.HasPart, id)
+		if unchanged[i] {
+			return nil
+		}
+
+		key = revisionf(node.ID, node.Source.Revision)
+
+		if !reapplied {
+			stage(key)
+		} else if err = save(key, nodeMetadata()); err != nil {
+			return err
+		}
 
-		if update.PostPutNodeCallback != nil {
-			// FIXME: Should we handle the error?  Ignore it?
-			update.PostPutNodeCallback(node)
+		if err = r.put(key, encoded[i], nodeMetadata()); err != nil {
+			return fmt.Errorf("error storing node: %w", err)
 		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, r.rollback(staged, saved, err)
 	}
 
 	update.Page.About = make(map[string]string)
 
-	// Upload linked data objects.
-	for k, v := range update.Abouts {
-		var id string
+	keys := make([]string, 0, len(update.Abouts))
+	for k := range update.Abouts {
+		keys = append(keys, k)
+	}
+
+	// Stage linked data objects, concurrently.  Those of a re-applied revision overwrite the published ones.
+	err = forEach(len(keys), r.concurrency(), func(i int) error {
+		var data []byte
 		var err error
-		if id, err = r.PutAbout(&v); err != nil {
+		var thing = update.Abouts[keys[i]]
+
+		thing.ID = aboutf(makeAboutID(&update.Page, keys[i]))
+
+		if !prevAbouts[thing.ID] {
+			stage(thing.ID)
+		} else if err = save(thing.ID, aboutMetadata()); err != nil {
+			return err
+		}
+
+		if data, err = encodeJSON(&thing); err != nil {
+			return err
+		}
+
+		if err = r.put(thing.ID, data, aboutMetadata()); err != nil {
 			return fmt.Errorf("error storing linked data object: %w", err)
 		}
-		update.Page.About[k] = id
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, r.rollback(staged, saved, err)
 	}
 
-	// Overwrite the Page object
-	if postPID, err = r.PutPage(&update.Page); err != nil {
-		return err
+	for _, k := range keys {
+		update.Page.About[k] = aboutf(makeAboutID(&update.Page, k))
+		result.AboutsWritten = append(result.AboutsWritten, update.Page.About[k])
 	}
 
-	// This should NEVER happen (so it probably will).
-	if postPID != prePID {
-		return fmt.Errorf("committed Page ID does not match precalculated value: %s != %s", postPID, prePID)
+	update.Page.ID = prePID
+	page := update.Page
+
+	if err = validatePage(&page); err != nil {
+		return nil, r.rollback(staged, saved, err)
 	}
 
-	// Delete previous linked-data objects (if any)
+	if pageData, err = encodeJSON(&page); err != nil {
+		return nil, r.rollback(staged, saved, err)
+	}
+
+	// Events are emitted once all of the nodes are published (and in document order), but are recorded to the
+	// outbox (if any) ahead of the Page write, so that they survive a failure to complete the update; Recorded
+	// as pending, they are not delivered until the Page is written.
+	for i := range update.Nodes {
+		node := update.Nodes[i]
+
+		switch {
+		case unchanged[i]:
+			result.Nodes = append(result.Nodes, NodeResult{ID: node.ID, Name: node.Name, Status: NodeUnchanged})
+			continue
+		case prevParts[node.ID]:
+			result.Nodes = append(result.Nodes, NodeResult{ID: node.ID, Name: node.Name, Status: NodeUpdated})
+		default:
+			result.Nodes = append(result.Nodes, NodeResult{ID: node.ID, Name: node.Name, Status: NodeCreated})
+		}
+
+		events = append(events, &Event{Kind: EventNodeStored, ID: node.ID, Page: &page, Node: &node})
+	}
+
+	for _, k := range keys {
+		thing := update.Abouts[k]
+		thing.ID = page.About[k]
+		events = append(events, &Event{Kind: EventAboutStored, ID: thing.ID, Page: &page, About: &thing})
+	}
+
+	events = append(events, &Event{Kind: EventPageStored, ID: page.ID, Page: &page})
+
+	if pending, err = r.record(events, stage); err != nil {
+		return nil, r.rollback(staged, saved, err)
+	}
+
+	// Stage the Page's revision
+	if !reapplied {
+		stage(revisionf(page.ID, page.Source.Revision))
+	} else if err = save(revisionf(page.ID, page.Source.Revision), pageMetadata()); err != nil {
+		return nil, r.rollback(staged, saved, err)
+	}
+
+	if err = r.put(revisionf(page.ID, page.Source.Revision), pageData, pageMetadata()); err != nil {
+		return nil, r.rollback(staged, saved, err)
+	}
+
+	// Store new nodes, concurrently; These are not found (see: GetNode) until the Page that refers to them is
+	// published.  An object left by an earlier update (that was not garbage-collected) is restored on rollback.
+	err = forEach(len(update.Nodes), r.concurrency(), func(i int) error {
+		var err error
+		var node = update.Nodes[i]
+
+		if unchanged[i] || prevParts[node.ID] {
+			return nil
+		}
+
+		if err = save(node.ID, nodeMetadata()); err != nil {
+			return err
+		}
+
+		if err = r.put(node.ID, encoded[i], nodeMetadata()); err != nil {
+			return fmt.Errorf("error storing node: %w", err)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, r.rollback(staged, saved, err)
+	}
+
+	// Overwrite the Page object; This publishes the update.
+	if err = r.put(page.ID, pageData, pageMetadata()); err != nil {
+		return nil, r.rollback(staged, saved, err)
+	}
+
+	// Notify hooks; Their errors are returned once the update is otherwise complete (or along with those of the
+	// steps that follow, which do not affect its publication).
+	hookErr := r.Hooks.emit(events...)
+
+	// Release the update's events for delivery (see: Relay, which also releases those left pending)
+	if err = r.release(pending); err != nil {
+		return result, withHookError(err, hookErr)
+	}
+
+	// Promote changed nodes to current, concurrently.  Until a node is, GetNode resolves it to the revision of
+	// the published Page.
+	err = forEach(len(update.Nodes), r.concurrency(), func(i int) error {
+		var node = update.Nodes[i]
+
+		if unchanged[i] || !prevParts[node.ID] {
+			return nil
+		}
+
+		if err := r.put(node.ID, encoded[i], nodeMetadata()); err != nil {
+			return fmt.Errorf("error publishing node: %w", err)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return result, withHookError(err, hookErr)
+	}
+
+	// Delete previous linked-data objects (if any); Those of a re-applied revision are reused.
 	if prevPage != nil {
+		current := make(map[string]bool)
+		for _, id := range update.Page.About {
+			current[id] = true
+		}
+
 		for _, id := range prevPage.About {
-			r.DeleteAbout(id)
+			if current[id] {
+				continue
+			}
+			if err = r.DeleteAbout(id); err != nil {
+				return result, withHookError(fmt.Errorf("error deleting linked data object: %w", err), hookErr)
+			}
+			result.AboutsDeleted = append(result.AboutsDeleted, id)
 		}
 	}
 
 	// Perform indexing
-	return r.Index.Apply(update)
+	if err = r.Index.Apply(update); err != nil {
+		var perr *ErrPartialIndex
+		if errors.As(err, &perr) {
+			result.Unindexed = perr.Names
+		}
+		return result, withHookError(err, hookErr)
+	}
+
+	result.Indexed = true
+
+	// Garbage-collect the nodes of sections that were removed or renamed
+	if prevPage != nil {
+		if err = r.removeOrphans(prevPage, update, result); err != nil {
+			err = fmt.Errorf("error removing orphaned nodes: %w", err)
+		}
+	}
+
+	// Removals are recorded (and emitted) once they have happened, including those that precede a failure to
+	// remove the rest; Nodes that were not garbage-collected (the update failed to index, for example) are
+	// still stored.
+	events = nil
+	for _, node := range result.Nodes {
+		if node.Status == NodeRemoved {
+			events = append(events, &Event{Kind: EventNodeRemoved, ID: node.ID, Page: &page})
+		}
+	}
+
+	if _, rerr := r.record(events, nil); rerr != nil && err == nil {
+		err = rerr
+	}
+
+	if herr := r.Hooks.emit(events...); herr != nil && hookErr == nil {
+		hookErr = herr
+	}
+
+	if err != nil {
+		return result, withHookError(err, hookErr)
+	}
+
+	return result, hookErr
+}
+
+// Annotates an error of Apply with that of its hooks (if any)
+func withHookError(err, hookErr error) error {
+	if hookErr == nil {
+		return err
+	}
+	return fmt.Errorf("%w (hooks failed: %v)", err, hookErr)
+}
+
+// An object overwritten by Apply, as it was stored beforehand
+type savedObject struct {
+	key  string
+	data []byte
+	meta map[string]*string
+}
+
+// Restores the objects overwritten by a failed Apply, removes those it staged, and returns cause (annotated,
+// should the rollback fail as well).  Rollback is best-effort; A failure does not stop the remaining steps.
+func (r *Repository) rollback(staged []string, saved []savedObject, cause error) error {
+	var first error
+
+	for _, object := range saved {
+		if err := r.put(object.key, object.data, object.meta); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	if err := r.delete(staged); err != nil && first == nil {
+		first = err
+	}
+
+	if first != nil {
+		return fmt.Errorf("%w (rollback failed: %v)", cause, first)
+	}
+
+	return cause
+}
+
+// Returns true if the published version of a node (see: publishedNode) is its stored object, with the same content
+// hash.  Nodes stored before content hashes were introduced are assigned one as they are read (see: schema.go).
+func (r *Repository) isUnchanged(node *common.Node, prevPage *common.Page) (bool, error) {
+	var stored, published *common.Node
+	var err error
+
+	if stored, err = r.getNode(node.ID); err != nil {
+		var nerr *ErrNotFound
+		if errors.As(err, &nerr) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	// A node the previous version changed, but that was never promoted (see: Apply), is stale; It is changed, so
+	// that it is promoted now.
+	if published, err = r.publishedNode(stored, prevPage); err != nil {
+		return false, err
+	}
+
+	return published == stored && stored.ContentHash != "" && stored.ContentHash == node.ContentHash, nil
+}
+
+// Removes the nodes of a previous Page that are not a part of an update, along with any index entries the
+// update has made stale (those of removed or renamed sections, or of a renamed page).  Node IDs are derived
+// from section names, so a renamed section is an orphaned node plus a new one.  The revision history of
+// orphaned nodes is kept, so that previous revisions of the Page remain intact.  The nodes removed are added
+// to result.
+func (r *Repository) removeOrphans(prevPage *common.Page, update *Update, result *UpdateResult) error {
+	var authority = prevPage.Source.Authority
+	var current = make(map[string]bool)
+	var indexed = make(map[string]bool)
+	var renamed = prevPage.Name != update.Page.Name
+	var err error
+
+	for _, id := range update.Page.HasPart {
+		current[id] = true
+	}
+
+	// Index keys written by this update; These must survive, even if an orphan maps to the same key (for example,
+	// a section renamed in case only).
+	for _, n := range update.Nodes {
+		indexed[encodeNodeName(update.Page.Name, n.Name)] = true
+	}
+
+	for _, id := range prevPage.HasPart {
+		var node *common.Node
+
+		if current[id] && !renamed {
+			continue
+		}
+
+		if node, err = r.getNode(id); err != nil {
+			var nerr *ErrNotFound
+			if !errors.As(err, &nerr) {
+				return err
+			}
+		}
+
+		if node != nil && !indexed[encodeNodeName(prevPage.Name, node.Name)] {
+			if err = r.Index.RemoveNode(authority, prevPage.Name, node.Name); err != nil {
+				return fmt.Errorf("error removing node from index: %w", err)
+			}
+		}
+
+		if current[id] {
+			continue
+		}
+
+		if err = r.deleteNode(id); err != nil {
+			return err
+		}
+
+		removedNode := NodeResult{ID: id, Status: NodeRemoved}
+		if node != nil {
+			removedNode.Name = node.Name
+		}
+		result.Nodes = append(result.Nodes, removedNode)
+	}
+
+	if renamed {
+		if err = r.Index.RemovePage(authority, prevPage.Name); err != nil {
+			return fmt.Errorf("error removing page from index: %w", err)
+		}
+	}
+
+	return nil
 }
 
+const (
+	// The maximum number of keys that can be passed to a single DeleteObjects request.
+	maxDeleteKeys = 1000
+
+	// The maximum number of keys returned by a single ListObjectsV2 request.
+	maxListKeys = 1000
+)
+
 var (
 	// Regular expression that matches UUIDs
 	tidRegexp = regexp.MustCompile("[A-Za-z0-9]{8}-[A-Za-z0-9]{4}-[A-Za-z0-9]{4}-[A-Za-z0-9]{4}-[A-Za-z0-9]{12}")
 )
 
+// Invokes f for each index in [0, n), with at most limit invocations running concurrently.  Once an
+// invocation fails, no more are started (those already running are waited on), and the first error is
+// returned.
+func forEach(n, limit int, f func(i int) error) error {
+	var first error
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	var sem = make(chan struct{}, limit)
+
+	failed := func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return first != nil
+	}
+
+	for i := 0; i < n; i++ {
+		sem <- struct{}{}
+
+		if failed() {
+			<-sem
+			break
+		}
+
+		wg.Add(1)
+
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			if err := f(i); err != nil {
+				mutex.Lock()
+				defer mutex.Unlock()
+				if first == nil {
+					first = err
+				}
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	return first
+}
+
 // Helpers are helpful.
 func encodeJSON(v interface{}) ([]byte, error) {
 	var buffer *bytes.Buffer
@@ -410,43 +1366,61 @@ func encodeJSON(v interface{}) ([]byte, error) {
 
 func validateSource(source *common.Source) error {
 	if source.ID <= 0 {
-		return fmt.Errorf("uninitialized common.Source.ID attribute (+%v)", source)
+		return &ErrInvalid{message: fmt.Sprintf("uninitialized common.Source.ID attribute (+%v)", source)}
 	}
 	if source.Revision <= 0 {
-		return fmt.Errorf("uninitialized common.Source.Revision attribute (+%v)", source)
+		return &ErrInvalid{message: fmt.Sprintf("uninitialized common.Source.Revision attribute (+%v)", source)}
 	}
 	if !tidRegexp.Match([]byte(source.TimeUUID)) {
-		return fmt.Errorf("invalid common.Source.TimeUUID attribute (+%v)", source)
+		return &ErrInvalid{message: fmt.Sprintf("invalid common.Source.TimeUUID attribute (+%v)", source)}
 	}
 	if source.Authority == "" {
-		return fmt.Errorf("uninitialized common.Source.Authority attribute (+%v)", source)
+		return &ErrInvalid{message: fmt.Sprintf("uninitialized common.Source.Authority attribute (+%v)", source)}
 	}
 	return nil
 }
 
 func validatePage(page *common.Page) error {
 	if page.Name == "" {
-		return fmt.Errorf("uninitialized page.Name attribute (%+v)", page)
+		return &ErrInvalid{message: fmt.Sprintf("uninitialized page.Name attribute (%+v)", page)}
 	}
 	if page.URL == "" {
-		return fmt.Errorf("uninitialized page.URL attribute (%+v)", page)
+		return &ErrInvalid{message: fmt.Sprintf("uninitialized page.URL attribute (%+v)", page)}
 	}
 	if page.DateModified.IsZero() {
-		return fmt.Errorf("uninitialized page.DateModified attribute (%+v)", page)
+		return &ErrInvalid{message: fmt.Sprintf("uninitialized page.DateModified attribute (%+v)", page)}
 	}
 	if len(page.HasPart) < 1 {
-		return fmt.Errorf("zero-length page.HasPart attribute (%+v)", page)
+		return &ErrInvalid{message: fmt.Sprintf("zero-length page.HasPart attribute (%+v)", page)}
 	}
 	return validateSource(&page.Source)
 }
 
 func validateNode(node *common.Node) error {
 	if node.DateModified.IsZero() {
-		return fmt.Errorf("uninitialized node.DateModified attribute (%+v)", node)
+		return &ErrInvalid{message: fmt.Sprintf("uninitialized node.DateModified attribute (%+v)", node)}
 	}
 	return validateSource(&node.Source)
 }
 
+// Returns true if a is older than b.  MediaWiki revisions are compared, with ties broken by comparing the
+// timestamps of the respective TimeUUIDs (a re-render of the same revision).
+func isOlder(a, b common.Source) bool {
+	if a.Revision != b.Revision {
+		return a.Revision < b.Revision
+	}
+
+	ta, errA := uuid.Parse(a.TimeUUID)
+	tb, errB := uuid.Parse(b.TimeUUID)
+
+	// Only version 1 UUIDs have a timestamp to compare
+	if errA != nil || errB != nil || ta.Version() != 1 || tb.Version() != 1 {
+		return false
+	}
+
+	return ta.Time() < tb.Time()
+}
+
 func makeRandomID() string {
 	return uuid.New().String()
 }
@@ -475,6 +1449,48 @@ func makeNodeID(node *common.Node) string {
 	return asHex(hasher.Sum64())
 }
 
+// Prepares a Node for storage (assigning its ID and content hash), and returns it JSON-encoded.
+func encodeNode(node *common.Node) ([]byte, error) {
+	if err := validateNode(node); err != nil {
+		return nil, err
+	}
+
+	node.ID = nodef(makeNodeID(node))
+	node.ContentHash = makeContentHash(node)
+
+	return encodeJSON(node)
+}
+
+// Object metadata of pages, nodes, and of linked-data objects
+func pageMetadata() map[string]*string {
+	return map[string]*string{"type": aws.String("common.Page")}
+}
+
+func nodeMetadata() map[string]*string {
+	return map[string]*string{"type": aws.String("common.Node")}
+}
+
+func aboutMetadata() map[string]*string {
+	return map[string]*string{"type": aws.String("common.Thing")}
+}
+
+// Linked-data objects stored by Apply are keyed by page, revision, and vocabulary; Until the page is
+// written, no reader refers to those of a new revision.
+func makeAboutID(page *common.Page, vocabulary string) string {
+	hasher := newHash64()
+	hasher.Write([]byte(vocabulary))
+	return fmt.Sprintf("%s-%d-%s", makePageID(page), page.Source.Revision, asHex(hasher.Sum64()))
+}
+
+// A node's content hash is computed from its name and HTML; Other attributes (Source, DateModified, etc) change
+// with every revision of the page, whether or not the node itself did.
+func makeContentHash(node *common.Node) string {
+	hasher := newHash64()
+	hasher.Write([]byte(fmt.Sprintf("%d:%s", len(node.Name), node.Name)))
+	hasher.Write([]byte(node.Unsafe))
+	return asHex(hasher.Sum64())
+}
+
 // Return formatted keys for page, node, and data objects.
 func pagef(id string) string {
 	return fmt.Sprintf("/page/%s", id)
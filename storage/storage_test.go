@@ -7,10 +7,11 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -102,73 +103,27 @@ func setup() {
 
 // MockStore is a mock implementation of S3 storage
 type MockStore struct {
-	Pages  map[string]common.Page
-	Nodes  map[string]common.Node
-	Abouts map[string]common.Thing
-	Topics map[string][]common.RelatedTopic
+	Objects  map[string][]byte
+	Metadata map[string]map[string]*string
+
+	// Repository.Apply stores objects concurrently
+	mutex sync.Mutex
 }
 
 // GetObject is a mock of s3.S3#GetObject
 func (store *MockStore) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
-	var present bool
 	var b []byte
-	var err error
-
-	switch {
-	case strings.HasPrefix(*input.Key, "/page"):
-		var page common.Page
-
-		// Not found
-		if page, present = store.Pages[*input.Key]; !present {
-			return nil, awserr.New(s3.ErrCodeNoSuchKey, "Not found", nil)
-		}
-
-		if b, err = json.Marshal(&page); err != nil {
-			return nil, fmt.Errorf("unabled to marshal Page to JSON: %w", err)
-		}
-
-	case strings.HasPrefix(*input.Key, "/node"):
-		var node common.Node
-
-		// Not found
-		if node, present = store.Nodes[*input.Key]; !present {
-			return nil, awserr.New(s3.ErrCodeNoSuchKey, "Not found", nil)
-		}
-
-		if b, err = json.Marshal(&node); err != nil {
-			return nil, fmt.Errorf("unabled to marshal Node to JSON: %w", err)
-		}
-
-	case strings.HasPrefix(*input.Key, "/data"):
-		var about common.Thing
-
-		// Not found
-		if about, present = store.Abouts[*input.Key]; !present {
-			return nil, awserr.New(s3.ErrCodeNoSuchKey, "Not found", nil)
-		}
-
-		if b, err = json.Marshal(&about); err != nil {
-			return nil, fmt.Errorf("unabled to marshal Node to JSON: %w", err)
-		}
-
-	case strings.HasPrefix(*input.Key, "/topics"):
-		var topics []common.RelatedTopic
-
-		// Not Found
-		if topics, present = store.Topics[*input.Key]; !present {
-			return nil, awserr.New(s3.ErrCodeNoSuchKey, "Not found", nil)
-		}
-
-		if b, err = json.Marshal(&topics); err != nil {
-			return nil, fmt.Errorf("unabled to marshal Node to JSON: %w", err)
-		}
+	var present bool
 
-	default:
-		return nil, fmt.Errorf("unrecognized key format (%s)", *input.Key)
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
 
+	// Not found
+	if b, present = store.Objects[*input.Key]; !present {
+		return nil, awserr.New(s3.ErrCodeNoSuchKey, "Not found", nil)
 	}
 
-	return &s3.GetObjectOutput{Body: aws.ReadSeekCloser(bufio.NewReader(bytes.NewBuffer(b)))}, nil
+	return &s3.GetObjectOutput{Body: aws.ReadSeekCloser(bufio.NewReader(bytes.NewBuffer(b))), Metadata: store.Metadata[*input.Key]}, nil
 }
 
 // PutObject is a mock of s3.S3#PutObject
@@ -181,63 +136,53 @@ func (store *MockStore) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput
 		return nil, err
 	}
 
-	switch {
-	case strings.HasPrefix(*input.Key, "/page"):
-		page := common.Page{}
-
-		if err = json.Unmarshal(b, &page); err != nil {
-			return nil, fmt.Errorf("unable to deserialize Page: %w", err)
-		}
-
-		store.Pages[*input.Key] = page
+	// Everything the Repository stores is JSON
+	if !json.Valid(b) {
+		return nil, fmt.Errorf("invalid JSON object (%s)", *input.Key)
+	}
 
-	case strings.HasPrefix(*input.Key, "/node"):
-		node := common.Node{}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
 
-		if err = json.Unmarshal(b, &node); err != nil {
-			return nil, fmt.Errorf("unable to deserialize Node: %w", err)
-		}
+	store.Objects[*input.Key] = b
+	store.Metadata[*input.Key] = input.Metadata
 
-		store.Nodes[*input.Key] = node
-
-	case strings.HasPrefix(*input.Key, "/data"):
-		about := common.Thing{}
+	return &s3.PutObjectOutput{}, nil
+}
 
-		if err = json.Unmarshal(b, &about); err != nil {
-			return nil, fmt.Errorf("unable to deserialize Thing: %w", err)
-		}
+// DeleteObjects is a mock of s3.S3#DeleteObjects
+func (store *MockStore) DeleteObjects(input *s3.DeleteObjectsInput) (*s3.DeleteObjectsOutput, error) {
+	var output = &s3.DeleteObjectsOutput{}
 
-		store.Abouts[*input.Key] = about
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
 
-	case strings.HasPrefix(*input.Key, "/topics"):
-		topics := []common.RelatedTopic{}
+	// Like S3, deleting a key that does not exist is not an error
+	for _, object := range input.Delete.Objects {
+		delete(store.Objects, *object.Key)
+		delete(store.Metadata, *object.Key)
+		output.Deleted = append(output.Deleted, &s3.DeletedObject{Key: object.Key})
+	}
 
-		if err = json.Unmarshal(b, &topics); err != nil {
-			return nil, fmt.Errorf("unable to deserialize Thing: %w", err)
-		}
+	return output, nil
+}
 
-		store.Topics[*input.Key] = topics
+// ListObjectsV2 is a mock of s3.S3#ListObjectsV2
+func (store *MockStore) ListObjectsV2(input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+	var keys []string
 
-	default:
-		return nil, fmt.Errorf("unrecognized key format (%s)", *input.Key)
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
 
+	for key := range store.Objects {
+		keys = append(keys, key)
 	}
 
-	return &s3.PutObjectOutput{}, nil
-}
-
-func (store *MockStore) DeleteObjects(input *s3.DeleteObjectsInput) (*s3.DeleteObjectsOutput, error) {
-	log.Println("DeleteObjects() not (yet) implemented")
-	return &s3.DeleteObjectsOutput{}, nil
+	return listObjectsV2(keys, input), nil
 }
 
 func NewMockStore() *MockStore {
-	return &MockStore{
-		Pages:  make(map[string]common.Page),
-		Nodes:  make(map[string]common.Node),
-		Abouts: make(map[string]common.Thing),
-		Topics: make(map[string][]common.RelatedTopic),
-	}
+	return &MockStore{Objects: make(map[string][]byte), Metadata: make(map[string]map[string]*string)}
 }
 
 // Get an environment variable if set, or a default otherwise.
@@ -267,7 +212,11 @@ func GetTestIndex() Index {
 
 	if useES {
 		client, _ := elasticsearch.NewDefaultClient()
-		return &ElasticsearchIndex{Client: client}
+		return &ElasticsearchIndex{
+			Client:    client,
+			PageIndex: Getenv("ELASTICSEARCH_PAGE_INDEX", "page_name"),
+			NodeIndex: Getenv("ELASTICSEARCH_NODE_INDEX", "node_name"),
+		}
 	}
 
 	if useDynamoDB {
@@ -371,7 +320,8 @@ func TestRepositoryApply(t *testing.T) {
 			Abouts: map[string]common.Thing{"//schema.org": testAbout},
 		}
 
-		require.Nil(t, repo.Apply(update))
+		_, err := repo.Apply(update)
+		require.Nil(t, err)
 
 		// Retrieving a page by its ID
 		page, err := repo.GetPage(testPage.ID)
@@ -414,6 +364,885 @@ func TestRepositoryApply(t *testing.T) {
 	})
 }
 
+func TestRepositoryDelete(t *testing.T) {
+	index := GetTestIndex()
+	topicSearch := NewMockTopicSearch()
+	repo := Repository{Store: GetTestStore(), Index: index, Bucket: Getenv("AWS_BUCKET", "scpoc-structured-content-store"), TopicSearch: topicSearch}
+
+	source := testSource
+	source.ID = 2
+
+	page := testPage
+	page.Source = source
+	page.Name = "New Braunfels"
+
+	node := testNode
+	node.Source = source
+
+	update := &Update{
+		Page:   page,
+		Nodes:  []common.Node{node},
+		Abouts: map[string]common.Thing{"//schema.org": testAbout},
+	}
+
+	_, err := repo.Apply(update)
+	require.Nil(t, err)
+
+	stored, err := repo.GetPageByName(source.Authority, page.Name)
+	require.Nil(t, err)
+	require.Len(t, stored.HasPart, 1)
+
+	storedNode, err := repo.GetNode(stored.HasPart[0])
+	require.Nil(t, err)
+	require.Nil(t, repo.PutTopics(storedNode, testTopics))
+	_, err = topicSearch.Update(storedNode, testTopics)
+	require.Nil(t, err)
+
+	t.Run("DeletePage", func(t *testing.T) {
+		require.Nil(t, repo.DeletePage(stored.ID))
+
+		var notFound *ErrNotFound
+
+		_, err := repo.GetPage(stored.ID)
+		assert.True(t, errors.As(err, &notFound), "Page object was not deleted")
+
+		_, err = repo.GetNode(storedNode.ID)
+		assert.True(t, errors.As(err, &notFound), "Node object was not deleted")
+
+		_, err = repo.ListRevisions(stored.ID)
+		assert.True(t, errors.As(err, &notFound), "Page revisions were not deleted")
+
+		_, err = repo.GetAbout(stored.About["//schema.org"])
+		assert.True(t, errors.As(err, &notFound), "Linked data object was not deleted")
+
+		_, err = repo.GetTopics(storedNode)
+		assert.True(t, errors.As(err, &notFound), "Related topics object was not deleted")
+
+		_, err = index.PageIDForName(source.Authority, page.Name)
+		assert.True(t, errors.As(err, &notFound), "Page index entry was not removed")
+
+		_, err = index.NodeIDForName(source.Authority, page.Name, node.Name)
+		assert.True(t, errors.As(err, &notFound), "Node index entry was not removed")
+
+		ids, err := topicSearch.Search(testTopics[0].ID)
+		require.Nil(t, err)
+		assert.Len(t, ids, 0, "Topic search entries were not removed")
+	})
+	t.Run("DeletePage (not found)", func(t *testing.T) {
+		err := repo.DeletePage(stored.ID)
+		require.NotNil(t, err)
+		var notFound *ErrNotFound
+		require.True(t, errors.As(err, &notFound))
+	})
+}
+
+func TestRepositoryApplyOrphans(t *testing.T) {
+	index := GetTestIndex()
+	repo := Repository{Store: GetTestStore(), Index: index, Bucket: Getenv("AWS_BUCKET", "scpoc-structured-content-store")}
+
+	source := testSource
+	source.ID = 3
+
+	page := testPage
+	page.Source = source
+	page.Name = "Seguin"
+
+	history := testNode
+	history.Source = source
+	history.Name = "History"
+
+	geography := testNode
+	geography.Source = source
+	geography.Name = "Geography"
+
+	_, err := repo.Apply(&Update{Page: page, Nodes: []common.Node{history, geography}})
+	require.Nil(t, err)
+
+	stored, err := repo.GetPageByName(source.Authority, page.Name)
+	require.Nil(t, err)
+	require.Len(t, stored.HasPart, 2)
+	orphanID := stored.HasPart[1]
+
+	// Rename the "Geography" section, and the page along with it
+	renamed := geography
+	renamed.Name = "Geography and climate"
+	page.Name = "Seguin, Texas"
+	page.Source.Revision++
+
+	_, err = repo.Apply(&Update{Page: page, Nodes: []common.Node{history, renamed}})
+	require.Nil(t, err)
+
+	var notFound *ErrNotFound
+
+	_, err = repo.GetNode(orphanID)
+	assert.True(t, errors.As(err, &notFound), "Orphaned node was not deleted")
+
+	_, err = index.NodeIDForName(source.Authority, "Seguin", "Geography")
+	assert.True(t, errors.As(err, &notFound), "Orphaned node index entry was not removed")
+
+	_, err = index.NodeIDForName(source.Authority, "Seguin", "History")
+	assert.True(t, errors.As(err, &notFound), "Node index entry of renamed page was not removed")
+
+	_, err = index.PageIDForName(source.Authority, "Seguin")
+	assert.True(t, errors.As(err, &notFound), "Page index entry of renamed page was not removed")
+
+	node, err := repo.GetNodeByName(source.Authority, page.Name, "History")
+	require.Nil(t, err)
+	assert.Equal(t, stored.HasPart[0], node.ID)
+
+	node, err = repo.GetNodeByName(source.Authority, page.Name, "Geography and climate")
+	require.Nil(t, err)
+	assert.NotEqual(t, orphanID, node.ID)
+
+	// The orphan's history is retained until the page is deleted
+	_, err = repo.ListRevisions(orphanID)
+	require.Nil(t, err)
+
+	require.Nil(t, repo.DeletePage(stored.ID))
+
+	_, err = repo.ListRevisions(orphanID)
+	assert.True(t, errors.As(err, &notFound), "Orphaned node revisions were not deleted")
+}
+
+func TestRepositoryRevisions(t *testing.T) {
+	repo := Repository{Store: GetTestStore(), Index: GetTestIndex(), Bucket: Getenv("AWS_BUCKET", "scpoc-structured-content-store")}
+
+	page := testPage
+	page.Source.ID = 4
+	page.Source.Revision = 10
+	page.Name = "Kyle"
+
+	node := testNode
+	node.Source = page.Source
+
+	_, err := repo.PutPage(&page)
+	require.Nil(t, err)
+	_, err = repo.PutNode(&node)
+	require.Nil(t, err)
+
+	// A subsequent revision
+	page.Source.Revision = 12
+	page.URL = "//fake.wikipedia.org/wiki/Kyle,_Texas"
+	node.Source = page.Source
+	node.Unsafe = "<h1>History</h1><p>Kyle was founded in 1880...</p>"
+
+	_, err = repo.PutPage(&page)
+	require.Nil(t, err)
+	_, err = repo.PutNode(&node)
+	require.Nil(t, err)
+
+	t.Run("ListRevisions", func(t *testing.T) {
+		revisions, err := repo.ListRevisions(page.ID)
+		require.Nil(t, err)
+		assert.Equal(t, []int{10, 12}, revisions)
+	})
+	t.Run("GetPageAtRevision", func(t *testing.T) {
+		p, err := repo.GetPageAtRevision(page.ID, 10)
+		require.Nil(t, err)
+		assert.Equal(t, page.ID, p.ID)
+		assert.Equal(t, "//fake.wikipedia.org/wiki/San_Antonio", p.URL)
+
+		// Revisions in between resolve to the most recent one preceding
+		p, err = repo.GetPageAtRevision(page.ID, 11)
+		require.Nil(t, err)
+		assert.Equal(t, 10, p.Source.Revision)
+
+		p, err = repo.GetPageAtRevision(page.ID, 12)
+		require.Nil(t, err)
+		assert.Equal(t, page.URL, p.URL)
+
+		// The current version is unaffected
+		p, err = repo.GetPage(page.ID)
+		require.Nil(t, err)
+		assert.Equal(t, 12, p.Source.Revision)
+	})
+	t.Run("GetPageAtRevision (not found)", func(t *testing.T) {
+		_, err := repo.GetPageAtRevision(page.ID, 9)
+		var notFound *ErrNotFound
+		require.True(t, errors.As(err, &notFound))
+	})
+	t.Run("GetNodeAtRevision", func(t *testing.T) {
+		n, err := repo.GetNodeAtRevision(node.ID, 11)
+		require.Nil(t, err)
+		assert.Equal(t, node.ID, n.ID)
+		assert.Equal(t, testNode.Unsafe, n.Unsafe)
+
+		n, err = repo.GetNodeAtRevision(node.ID, 100)
+		require.Nil(t, err)
+		assert.Equal(t, node.Unsafe, n.Unsafe)
+	})
+	t.Run("DeleteNode", func(t *testing.T) {
+		require.Nil(t, repo.DeleteNode(node.ID))
+
+		var notFound *ErrNotFound
+		_, err := repo.ListRevisions(node.ID)
+		require.True(t, errors.As(err, &notFound))
+		_, err = repo.GetNodeAtRevision(node.ID, 10)
+		require.True(t, errors.As(err, &notFound))
+	})
+}
+
+func TestRepositoryApplyStale(t *testing.T) {
+	repo := Repository{Store: GetTestStore(), Index: GetTestIndex(), Bucket: Getenv("AWS_BUCKET", "scpoc-structured-content-store")}
+
+	newer := testPage
+	newer.Source.ID = 5
+	newer.Source.Revision = 20
+	newer.Name = "Buda"
+
+	older := newer
+	older.Source.Revision = 19
+
+	node := testNode
+	node.Source = newer.Source
+
+	_, err := repo.Apply(&Update{Page: newer, Nodes: []common.Node{node}})
+	require.Nil(t, err)
+
+	t.Run("Older revision", func(t *testing.T) {
+		_, err := repo.Apply(&Update{Page: older, Nodes: []common.Node{node}})
+		var stale *ErrStaleRevision
+		require.True(t, errors.As(err, &stale), "Expected an error of type ErrStaleRevision")
+
+		page, err := repo.GetPageByName(newer.Source.Authority, newer.Name)
+		require.Nil(t, err)
+		assert.Equal(t, newer.Source.Revision, page.Source.Revision)
+	})
+	t.Run("Older render of the same revision", func(t *testing.T) {
+		// Rendered earlier than the revision stored
+		rerender := newer
+		rerender.Source.TimeUUID = uuid.Must(uuid.NewUUID()).String()
+		_, err := repo.Apply(&Update{Page: rerender, Nodes: []common.Node{node}})
+		require.Nil(t, err)
+
+		_, err = repo.Apply(&Update{Page: newer, Nodes: []common.Node{node}})
+		var stale *ErrStaleRevision
+		require.True(t, errors.As(err, &stale), "Expected an error of type ErrStaleRevision")
+	})
+	t.Run("Forced rollback", func(t *testing.T) {
+		_, err := repo.Apply(&Update{Page: older, Nodes: []common.Node{node}, Force: true})
+		require.Nil(t, err)
+
+		page, err := repo.GetPageByName(newer.Source.Authority, newer.Name)
+		require.Nil(t, err)
+		assert.Equal(t, older.Source.Revision, page.Source.Revision)
+	})
+}
+
+func TestRepositoryApplyUnchanged(t *testing.T) {
+	repo := Repository{Store: GetTestStore(), Index: GetTestIndex(), Bucket: Getenv("AWS_BUCKET", "scpoc-structured-content-store"), Hooks: &Hooks{}}
+
+	var called []string
+
+	repo.Hooks.Subscribe(func(event *Event) error {
+		called = append(called, event.Node.Name)
+		return nil
+	}, HookFail, EventNodeStored)
+
+	page := testPage
+	page.Source.ID = 6
+	page.Source.Revision = 30
+	page.Name = "Wimberley"
+
+	history := testNode
+	history.Name = "History"
+
+	geography := testNode
+	geography.Name = "Geography"
+
+	culture := testNode
+	culture.Name = "Culture"
+
+	update := &Update{Page: page, Nodes: []common.Node{history, geography, culture}}
+	result, err := repo.Apply(update)
+	require.Nil(t, err)
+	require.Len(t, result.Nodes, 3)
+	for _, node := range result.Nodes {
+		assert.Equal(t, NodeCreated, node.Status)
+	}
+	assert.Equal(t, []string{"History", "Geography", "Culture"}, called)
+
+	// IDs are reported in the result, not assigned to the update
+	assert.Equal(t, Update{Page: page, Nodes: []common.Node{history, geography, culture}}, *update)
+
+	first, err := repo.GetPage(pagef(makePageID(&page)))
+	require.Nil(t, err)
+
+	// A subsequent revision; History is unchanged, Geography edited, Culture removed, and Economy added.
+	page.Source.Revision = 31
+	geography.Unsafe = "<h1>Geography</h1><p>Wimberley is located on the Blanco River...</p>"
+
+	economy := testNode
+	economy.Name = "Economy"
+
+	called = nil
+
+	result, err = repo.Apply(&Update{Page: page, Nodes: []common.Node{history, geography, economy}})
+	require.Nil(t, err)
+
+	require.Len(t, result.Nodes, 4)
+	assert.Equal(t, NodeResult{first.HasPart[0], "History", NodeUnchanged}, result.Nodes[0])
+	assert.Equal(t, NodeResult{first.HasPart[1], "Geography", NodeUpdated}, result.Nodes[1])
+	assert.Equal(t, "Economy", result.Nodes[2].Name)
+	assert.Equal(t, NodeCreated, result.Nodes[2].Status)
+	assert.Equal(t, NodeResult{first.HasPart[2], "Culture", NodeRemoved}, result.Nodes[3])
+	assert.Equal(t, []string{"Geography", "Economy"}, called)
+
+	// The unchanged node was not rewritten, but remains a part of the page
+	revisions, err := repo.ListRevisions(first.HasPart[0])
+	require.Nil(t, err)
+	assert.Equal(t, []int{30}, revisions)
+
+	stored, err := repo.GetPage(first.ID)
+	require.Nil(t, err)
+	assert.Equal(t, []string{first.HasPart[0], first.HasPart[1], result.Nodes[2].ID}, stored.HasPart)
+
+	node, err := repo.GetNodeAtRevision(first.HasPart[0], 31)
+	require.Nil(t, err)
+	assert.Equal(t, history.Unsafe, node.Unsafe)
+}
+
+func TestRepositoryApplyResult(t *testing.T) {
+	index := &failingIndex{MockIndex: NewMockIndex(), remaining: -1}
+	repo := Repository{Store: NewMockStore(), Index: index, Bucket: "test"}
+
+	page := testPage
+	page.Source.Revision = 50
+	page.Name = "Lockhart"
+
+	history := testNode
+	history.Name = "History"
+
+	culture := testNode
+	culture.Name = "Culture"
+
+	result, err := repo.Apply(&Update{Page: page, Nodes: []common.Node{history, culture}, Abouts: map[string]common.Thing{"//schema.org": testAbout}})
+	require.Nil(t, err)
+
+	first, err := repo.GetPage(result.PageID)
+	require.Nil(t, err)
+
+	assert.Equal(t, 50, result.Revision)
+	assert.Equal(t, 0, result.PreviousRevision)
+	assert.Equal(t, []NodeResult{{first.HasPart[0], "History", NodeCreated}, {first.HasPart[1], "Culture", NodeCreated}}, result.Nodes)
+	assert.Equal(t, []string{first.About["//schema.org"]}, result.AboutsWritten)
+	assert.Empty(t, result.AboutsDeleted)
+	assert.True(t, result.Indexed)
+
+	// A subsequent revision; History is edited, and Culture removed.
+	page.Source.Revision = 51
+	history.Unsafe = "<h1>History</h1><p>Lockhart was founded in 1848...</p>"
+
+	result, err = repo.Apply(&Update{Page: page, Nodes: []common.Node{history}, Abouts: map[string]common.Thing{"//schema.org": testAbout}})
+	require.Nil(t, err)
+
+	second, err := repo.GetPage(result.PageID)
+	require.Nil(t, err)
+
+	assert.Equal(t, 51, result.Revision)
+	assert.Equal(t, 50, result.PreviousRevision)
+	assert.Equal(t, []NodeResult{{first.HasPart[0], "History", NodeUpdated}, {first.HasPart[1], "Culture", NodeRemoved}}, result.Nodes)
+	assert.Equal(t, []string{second.About["//schema.org"]}, result.AboutsWritten)
+	assert.Equal(t, []string{first.About["//schema.org"]}, result.AboutsDeleted)
+
+	t.Run("Index failure", func(t *testing.T) {
+		index.remaining = 0
+		page.Source.Revision = 52
+
+		// The update is published, and reported as such
+		result, err := repo.Apply(&Update{Page: page, Nodes: []common.Node{history}})
+		require.NotNil(t, err)
+		require.NotNil(t, result)
+		assert.False(t, result.Indexed)
+		assert.Equal(t, []NodeResult{{first.HasPart[0], "History", NodeUnchanged}}, result.Nodes)
+
+		stored, err := repo.GetPage(result.PageID)
+		require.Nil(t, err)
+		assert.Equal(t, 52, stored.Source.Revision)
+	})
+}
+
+// A Store that tracks the number of concurrent PutObject requests, and fails those for keys with a given prefix
+// (when failPrefix is set), and every GetObject request (when getError is set)
+type instrumentedStore struct {
+	*MockStore
+	failPrefix  string
+	getError    error
+	deleteError error
+	beforePut   func(key string)
+	inFlight    int32
+	maxInFlight int32
+}
+
+func (store *instrumentedStore) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	if store.getError != nil {
+		return nil, store.getError
+	}
+	return store.MockStore.GetObject(input)
+}
+
+func (store *instrumentedStore) DeleteObjects(input *s3.DeleteObjectsInput) (*s3.DeleteObjectsOutput, error) {
+	if store.deleteError != nil {
+		return nil, store.deleteError
+	}
+	return store.MockStore.DeleteObjects(input)
+}
+
+func (store *instrumentedStore) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	n := atomic.AddInt32(&store.inFlight, 1)
+	defer atomic.AddInt32(&store.inFlight, -1)
+
+	for {
+		max := atomic.LoadInt32(&store.maxInFlight)
+		if n <= max || atomic.CompareAndSwapInt32(&store.maxInFlight, max, n) {
+			break
+		}
+	}
+
+	time.Sleep(time.Millisecond)
+
+	if store.beforePut != nil {
+		store.beforePut(*input.Key)
+	}
+
+	if store.failPrefix != "" && strings.HasPrefix(*input.Key, store.failPrefix) {
+		return nil, fmt.Errorf("failed to store %s", *input.Key)
+	}
+
+	return store.MockStore.PutObject(input)
+}
+
+func TestRepositoryApplyConcurrency(t *testing.T) {
+	var nodes []common.Node
+
+	page := testPage
+	page.Source.ID = 7
+	page.Name = "Lockhart"
+
+	for i := 0; i < 50; i++ {
+		node := testNode
+		node.Name = fmt.Sprintf("Section %d", i)
+		nodes = append(nodes, node)
+	}
+
+	t.Run("Document order", func(t *testing.T) {
+		store := &instrumentedStore{MockStore: NewMockStore()}
+		repo := Repository{Store: store, Index: NewMockIndex(), Bucket: "test", Concurrency: 4}
+
+		update := &Update{Page: page, Nodes: append([]common.Node{}, nodes...), Abouts: map[string]common.Thing{"//schema.org": testAbout}}
+		_, err := repo.Apply(update)
+		require.Nil(t, err)
+
+		stored, err := repo.GetPage(pagef(makePageID(&page)))
+		require.Nil(t, err)
+		require.Len(t, stored.HasPart, len(nodes))
+		require.Len(t, stored.About, 1)
+
+		for i, id := range stored.HasPart {
+			node, err := repo.GetNode(id)
+			require.Nil(t, err)
+			assert.Equal(t, nodes[i].Name, node.Name)
+		}
+
+		assert.True(t, store.maxInFlight > 1, "Expected concurrent uploads")
+		assert.True(t, store.maxInFlight <= 4, "Concurrency limit exceeded (%d)", store.maxInFlight)
+	})
+	t.Run("Failure", func(t *testing.T) {
+		store := &instrumentedStore{MockStore: NewMockStore(), failPrefix: revisionsf(nodef(""))}
+		repo := Repository{Store: store, Index: NewMockIndex(), Bucket: "test", Concurrency: 4}
+
+		_, err := repo.Apply(&Update{Page: page, Nodes: append([]common.Node{}, nodes...)})
+		require.NotNil(t, err)
+
+		// Remaining work is canceled, and the page is never stored
+		var notFound *ErrNotFound
+		_, err = repo.GetPage(pagef(makePageID(&page)))
+		assert.True(t, errors.As(err, &notFound))
+		assert.True(t, len(store.Objects) < len(nodes), "Expected remaining uploads to be canceled")
+	})
+}
+
+func TestRepositoryApplyAtomic(t *testing.T) {
+	store := &instrumentedStore{MockStore: NewMockStore()}
+	repo := Repository{Store: store, Index: NewMockIndex(), Bucket: "test"}
+
+	page := testPage
+	page.Source.ID = 8
+	page.Source.Revision = 40
+	page.Name = "Martindale"
+
+	history := testNode
+	history.Name = "History"
+
+	geography := testNode
+	geography.Name = "Geography"
+
+	_, err := repo.Apply(&Update{Page: page, Nodes: []common.Node{history, geography}, Abouts: map[string]common.Thing{"//schema.org": testAbout}})
+	require.Nil(t, err)
+
+	published, err := repo.GetPage(pagef(makePageID(&page)))
+	require.Nil(t, err)
+
+	// Asserts that the published version is intact, and that nothing staged by a failed update remains
+	assertIntact := func(t *testing.T, objects int) {
+		var notFound *ErrNotFound
+
+		stored, err := repo.GetPage(published.ID)
+		require.Nil(t, err)
+		assert.Equal(t, published, stored)
+
+		node, err := repo.GetNode(published.HasPart[1])
+		require.Nil(t, err)
+		assert.Equal(t, testNode.Unsafe, node.Unsafe)
+
+		revisions, err := repo.ListRevisions(published.HasPart[1])
+		require.Nil(t, err)
+		assert.Equal(t, []int{40}, revisions)
+
+		_, err = repo.GetNodeAtRevision(published.HasPart[1], 40)
+		require.Nil(t, err)
+
+		_, err = repo.GetAbout(published.About["//schema.org"])
+		require.Nil(t, err)
+
+		_, err = repo.GetNodeByName(page.Source.Authority, page.Name, "Economy")
+		assert.True(t, errors.As(err, &notFound))
+
+		assert.Len(t, store.Objects, objects)
+	}
+
+	objects := len(store.Objects)
+
+	// A subsequent revision; Geography is edited, and Economy added.
+	update := func(revision int) *Update {
+		next := page
+		next.Source.Revision = revision
+		next.Source.TimeUUID = uuid.Must(uuid.NewUUID()).String()
+
+		edited := geography
+		edited.Unsafe = "<h1>Geography</h1><p>Martindale is located on the San Marcos River...</p>"
+
+		economy := testNode
+		economy.Name = "Economy"
+
+		return &Update{Page: next, Nodes: []common.Node{history, edited, economy}, Abouts: map[string]common.Thing{"//schema.org": testAbout}}
+	}
+
+	t.Run("Linked data failure", func(t *testing.T) {
+		store.failPrefix = aboutf("")
+		defer func() { store.failPrefix = "" }()
+
+		_, err := repo.Apply(update(41))
+		require.NotNil(t, err)
+		assertIntact(t, objects)
+	})
+	t.Run("Page failure", func(t *testing.T) {
+		store.failPrefix = revisionsf(pagef(""))
+		defer func() { store.failPrefix = "" }()
+
+		_, err := repo.Apply(update(41))
+		require.NotNil(t, err)
+		assertIntact(t, objects)
+	})
+	t.Run("New node failure", func(t *testing.T) {
+		store.failPrefix = nodef("")
+		defer func() { store.failPrefix = "" }()
+
+		_, err := repo.Apply(update(41))
+		require.NotNil(t, err)
+		assertIntact(t, objects)
+	})
+	t.Run("Page object failure", func(t *testing.T) {
+		var checked bool
+
+		economy := testNode
+		economy.Name = "Economy"
+		economy.Source = page.Source
+
+		// Until the page object is written, nodes read by ID are those of the published version
+		store.failPrefix = pagef("")
+		store.beforePut = func(key string) {
+			var notFound *ErrNotFound
+
+			if !strings.HasPrefix(key, pagef("")) {
+				return
+			}
+
+			node, err := repo.GetNode(published.HasPart[1])
+			require.Nil(t, err)
+			assert.Equal(t, testNode.Unsafe, node.Unsafe)
+
+			_, err = repo.GetNode(nodef(makeNodeID(&economy)))
+			assert.True(t, errors.As(err, &notFound))
+
+			checked = true
+		}
+		defer func() {
+			store.failPrefix = ""
+			store.beforePut = nil
+		}()
+
+		_, err := repo.Apply(update(41))
+		require.NotNil(t, err)
+		require.True(t, checked)
+		assertIntact(t, objects)
+	})
+	t.Run("Page object failure (re-render)", func(t *testing.T) {
+		store.failPrefix = pagef("")
+		defer func() { store.failPrefix = "" }()
+
+		_, err := repo.Apply(update(40))
+		require.NotNil(t, err)
+		assertIntact(t, objects)
+
+		// The revision-scoped objects the re-render overwrote are restored
+		node, err := repo.GetNodeAtRevision(published.HasPart[1], 40)
+		require.Nil(t, err)
+		assert.Equal(t, testNode.Unsafe, node.Unsafe)
+
+		stored, err := repo.GetPageAtRevision(published.ID, 40)
+		require.Nil(t, err)
+		assert.Equal(t, published, stored)
+	})
+	t.Run("Success", func(t *testing.T) {
+		result, err := repo.Apply(update(41))
+		require.Nil(t, err)
+		require.Len(t, result.Nodes, 3)
+		assert.Equal(t, NodeCreated, result.Nodes[2].Status)
+
+		stored, err := repo.GetPage(published.ID)
+		require.Nil(t, err)
+		assert.Equal(t, 41, stored.Source.Revision)
+		assert.NotEqual(t, published.About["//schema.org"], stored.About["//schema.org"])
+
+		node, err := repo.GetNode(published.HasPart[1])
+		require.Nil(t, err)
+		assert.Equal(t, 41, node.Source.Revision)
+
+		revisions, err := repo.ListRevisions(published.HasPart[1])
+		require.Nil(t, err)
+		assert.Equal(t, []int{40, 41}, revisions)
+
+		// The linked data of the previous version is gone
+		var notFound *ErrNotFound
+		_, err = repo.GetAbout(published.About["//schema.org"])
+		assert.True(t, errors.As(err, &notFound))
+	})
+	t.Run("Promotion failure", func(t *testing.T) {
+		next := update(42)
+		next.Nodes[1].Unsafe = "<h1>Geography</h1><p>Martindale is located in Caldwell County...</p>"
+
+		// The page object is written, and so the update is published; Only the changed node is not promoted
+		store.failPrefix = published.HasPart[1]
+		result, err := repo.Apply(next)
+		store.failPrefix = ""
+		require.NotNil(t, err)
+		require.NotNil(t, result)
+
+		stored, err := repo.GetPage(published.ID)
+		require.Nil(t, err)
+		assert.Equal(t, 42, stored.Source.Revision)
+
+		// ...which is nonetheless read, by ID, from its revision history
+		node, err := repo.GetNode(published.HasPart[1])
+		require.Nil(t, err)
+		assert.Equal(t, 42, node.Source.Revision)
+		assert.Equal(t, next.Nodes[1].Unsafe, node.Unsafe)
+
+		// Re-rendering the revision promotes it, even if it is what the stale node object holds
+		_, err = repo.Apply(update(42))
+		require.Nil(t, err)
+
+		node, err = repo.getNode(published.HasPart[1])
+		require.Nil(t, err)
+		assert.Equal(t, 42, node.Source.Revision)
+		assert.Equal(t, update(42).Nodes[1].Unsafe, node.Unsafe)
+	})
+}
+
+func TestRepositoryBatch(t *testing.T) {
+	repo := Repository{Store: GetTestStore(), Index: GetTestIndex(), Bucket: Getenv("AWS_BUCKET", "scpoc-structured-content-store"), Concurrency: 4}
+
+	var ids []string
+
+	page := testPage
+	page.Source.ID = 11
+
+	for i := 0; i < 20; i++ {
+		node := testNode
+		node.Source = page.Source
+		node.Name = fmt.Sprintf("Section %d", i)
+
+		id, err := repo.PutNode(&node)
+		require.Nil(t, err)
+		ids = append(ids, id)
+	}
+
+	pid, err := repo.PutPage(&page)
+	require.Nil(t, err)
+
+	t.Run("GetNodes", func(t *testing.T) {
+		batch := append([]string{}, ids[:10]...)
+		batch = append(batch, "/node/bogus")
+		batch = append(batch, ids[10:]...)
+
+		nodes, err := repo.GetNodes(batch)
+		require.Nil(t, err)
+		require.Len(t, nodes, len(batch))
+
+		for i, node := range nodes {
+			if batch[i] == "/node/bogus" {
+				assert.Nil(t, node)
+				continue
+			}
+			require.NotNil(t, node)
+			assert.Equal(t, batch[i], node.ID)
+		}
+	})
+	t.Run("GetPages", func(t *testing.T) {
+		pages, err := repo.GetPages([]string{"/page/bogus", pid})
+		require.Nil(t, err)
+		require.Len(t, pages, 2)
+		assert.Nil(t, pages[0])
+		require.NotNil(t, pages[1])
+		assert.Equal(t, pid, pages[1].ID)
+	})
+	t.Run("Failure", func(t *testing.T) {
+		failing := Repository{Store: &instrumentedStore{MockStore: NewMockStore()}, Index: NewMockIndex(), Bucket: "test"}
+		failing.Store.(*instrumentedStore).getError = errors.New("unavailable")
+
+		_, err := failing.GetNodes(ids)
+		require.NotNil(t, err)
+	})
+}
+
+func TestRepositoryList(t *testing.T) {
+	repo := Repository{Store: NewMockStore(), Index: NewMockIndex(), Bucket: "test"}
+
+	var expected = make(map[string]bool)
+
+	for i := 1; i <= 8; i++ {
+		page := testPage
+		page.Source.ID = 100 + i
+		page.Source.Authority = "a.wikipedia.org"
+
+		if i > 5 {
+			page.Source.Authority = "b.wikipedia.org"
+		}
+
+		node := testNode
+		node.Source = page.Source
+
+		_, err := repo.PutNode(&node)
+		require.Nil(t, err)
+		id, err := repo.PutPage(&page)
+		require.Nil(t, err)
+
+		if page.Source.Authority == "a.wikipedia.org" {
+			expected[id] = true
+		}
+	}
+
+	// Also present: revision history and related topics, neither of which are listed
+	require.Nil(t, repo.PutTopics(&testNode, testTopics))
+
+	t.Run("ListPages", func(t *testing.T) {
+		var cursor string
+		var found = make(map[string]bool)
+
+		for i := 0; ; i++ {
+			pages, next, err := repo.ListPages("a.wikipedia.org", cursor, 2)
+			require.Nil(t, err)
+			require.True(t, len(pages) <= 2)
+
+			for _, page := range pages {
+				assert.Equal(t, "a.wikipedia.org", page.Source.Authority)
+				found[page.ID] = true
+			}
+
+			if next == "" {
+				break
+			}
+
+			require.True(t, i < 10, "Pagination did not terminate")
+			cursor = next
+		}
+
+		assert.Equal(t, expected, found)
+	})
+	t.Run("ListPages (all)", func(t *testing.T) {
+		pages, next, err := repo.ListPages("", "", 0)
+		require.Nil(t, err)
+		assert.Len(t, pages, 8)
+		assert.Equal(t, "", next)
+
+		for i := 1; i < len(pages); i++ {
+			assert.True(t, pages[i-1].ID < pages[i].ID, "Pages not listed in order of ID")
+		}
+	})
+	t.Run("ListNodes", func(t *testing.T) {
+		nodes, next, err := repo.ListNodes("b.wikipedia.org", "", 10)
+		require.Nil(t, err)
+		assert.Len(t, nodes, 3)
+		assert.Equal(t, "", next)
+
+		nodes, next, err = repo.ListNodes("", "", 5)
+		require.Nil(t, err)
+		assert.Len(t, nodes, 5)
+		require.NotEqual(t, "", next)
+
+		nodes, next, err = repo.ListNodes("", next, 5)
+		require.Nil(t, err)
+		assert.Len(t, nodes, 3)
+		assert.Equal(t, "", next)
+	})
+}
+
+func TestForEach(t *testing.T) {
+	t.Run("Bounded", func(t *testing.T) {
+		var inFlight, max int32
+		var visited = make([]bool, 100)
+
+		err := forEach(len(visited), 3, func(i int) error {
+			n := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+
+			for {
+				m := atomic.LoadInt32(&max)
+				if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+					break
+				}
+			}
+
+			time.Sleep(time.Millisecond)
+			visited[i] = true
+			return nil
+		})
+
+		require.Nil(t, err)
+		assert.True(t, max <= 3)
+		for i, v := range visited {
+			assert.True(t, v, "Index %d not visited", i)
+		}
+	})
+	t.Run("Canceled", func(t *testing.T) {
+		var calls int32
+
+		err := forEach(100, 2, func(i int) error {
+			atomic.AddInt32(&calls, 1)
+			if i == 5 {
+				return fmt.Errorf("failed at %d", i)
+			}
+			time.Sleep(time.Millisecond)
+			return nil
+		})
+
+		require.NotNil(t, err)
+		assert.Equal(t, "failed at 5", err.Error())
+		assert.True(t, calls < 100, "Expected remaining invocations to be canceled")
+	})
+}
+
 func TestValidation(t *testing.T) {
 	t.Run("Source", func(t *testing.T) {
 		require.Nil(t, validateSource(&common.Source{ID: 1, Revision: 1, TimeUUID: "61e16274-ed75-11ea-a791-9fba67228067", Authority: "s.wp.o"}))
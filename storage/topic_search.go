@@ -22,12 +22,62 @@ type TopicSearch interface {
 
 	// Update applies changes to the topic index
 	Update(node *common.Node, topics []common.RelatedTopic) (*UpdateStats, error)
+
+	// Delete removes all topic index entries for a node
+	Delete(id string) error
+}
+
+// MockTopicSearch is a memory-backed TopicSearch used in testing
+type MockTopicSearch struct {
+	topics map[string][]common.RelatedTopic
+}
+
+// Search queries the index for nodes matching a Wikidata ID
+func (t *MockTopicSearch) Search(qid string) ([]string, error) {
+	var ids = make([]string, 0)
+
+	for id, topics := range t.topics {
+		for _, topic := range topics {
+			if topic.ID == qid {
+				ids = append(ids, id)
+				break
+			}
+		}
+	}
+
+	return ids, nil
+}
+
+// Update applies changes to the topic index
+func (t *MockTopicSearch) Update(node *common.Node, topics []common.RelatedTopic) (*UpdateStats, error) {
+	t.topics[node.ID] = topics
+	n := uint64(len(topics))
+	return &UpdateStats{NumAdded: n, NumIndexed: n}, nil
+}
+
+// Delete removes all topic index entries for a node
+func (t *MockTopicSearch) Delete(id string) error {
+	delete(t.topics, id)
+	return nil
+}
+
+// NewMockTopicSearch creates a new MockTopicSearch
+func NewMockTopicSearch() *MockTopicSearch {
+	return &MockTopicSearch{make(map[string][]common.RelatedTopic)}
 }
 
 // ElasticTopicSearch is an Elasticsearch implementation of the TopicSearch interface.
 type ElasticTopicSearch struct {
 	Client    *elasticsearch.Client
 	IndexName string
+
+	ctx context.Context
+}
+
+// WithContext returns a copy of the topic search whose requests are made with ctx.
+func (t ElasticTopicSearch) WithContext(ctx context.Context) TopicSearch {
+	t.ctx = ctx
+	return t
 }
 
 // Search queries the index for nodes matching a Wikidata ID
@@ -41,7 +91,7 @@ func (t ElasticTopicSearch) Search(qid string) ([]string, error) {
 		return nil, err
 	}
 
-	if res, err = req.Do(context.Background(), t.Client); err != nil {
+	if res, err = req.Do(contextOrBackground(t.ctx), t.Client); err != nil {
 		return nil, fmt.Errorf("Elasticsearch response error: %w", err)
 	}
 
@@ -72,11 +122,11 @@ func (t ElasticTopicSearch) Update(node *common.Node, topics []common.RelatedTop
 	var stats UpdateStats
 
 	// Delete request matching this node
-	if req, err = t.deleteRequest(node); err != nil {
+	if req, err = t.deleteRequest(node.ID); err != nil {
 		return nil, err
 	}
 
-	if res, err = req.Do(context.Background(), t.Client); err != nil {
+	if res, err = req.Do(contextOrBackground(t.ctx), t.Client); err != nil {
 		return nil, fmt.Errorf("Elasticsearch response error: %w", err)
 	}
 
@@ -110,7 +160,7 @@ func (t ElasticTopicSearch) Update(node *common.Node, topics []common.RelatedTop
 		}
 
 		err = indexer.Add(
-			context.Background(),
+			contextOrBackground(t.ctx),
 			esutil.BulkIndexerItem{
 				Action: "index",
 				Body:   strings.NewReader(string(data)),
@@ -122,7 +172,7 @@ func (t ElasticTopicSearch) Update(node *common.Node, topics []common.RelatedTop
 		)
 	}
 
-	if err = indexer.Close(context.Background()); err != nil {
+	if err = indexer.Close(contextOrBackground(t.ctx)); err != nil {
 		return nil, fmt.Errorf("unexpected error encountered while closing the indexer %w", err)
 	}
 
@@ -131,12 +181,36 @@ func (t ElasticTopicSearch) Update(node *common.Node, topics []common.RelatedTop
 	return &stats, nil
 }
 
-// Convenience that returns a new DeleteByQueryRequest for the supplied Node
-func (t ElasticTopicSearch) deleteRequest(node *common.Node) (esapi.Request, error) {
+// Delete removes all topic index entries for a node
+func (t ElasticTopicSearch) Delete(id string) error {
+	var err error
+	var req esapi.Request
+	var res *esapi.Response
+
+	if req, err = t.deleteRequest(id); err != nil {
+		return err
+	}
+
+	if res, err = req.Do(contextOrBackground(t.ctx), t.Client); err != nil {
+		return fmt.Errorf("Elasticsearch response error: %w", err)
+	}
+
+	defer res.Body.Close()
+
+	// 404 is normal for a node that was never indexed
+	if res.IsError() && res.StatusCode != 404 {
+		return fmt.Errorf("error deleting entries for %s (status=%s)", id, res.Status())
+	}
+
+	return nil
+}
+
+// Convenience that returns a new DeleteByQueryRequest for the supplied Node ID
+func (t ElasticTopicSearch) deleteRequest(id string) (esapi.Request, error) {
 	query := map[string]interface{}{
 		"query": map[string]interface{}{
 			"match": map[string]interface{}{
-				"node_id": node.ID,
+				"node_id": id,
 			},
 		},
 	}